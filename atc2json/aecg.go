@@ -0,0 +1,194 @@
+package atc2json
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	hl7ActCodeSystem = "2.16.840.1.113883.5.4"
+	hl7MDCCodeSystem = "2.16.840.1.113883.6.24"
+	hl7TimeLayout    = "20060102150405.000-0700"
+)
+
+// aecgLeadCodes maps lead names to their MDC lead codes
+var aecgLeadCodes = map[string]string{
+	"leadI":   "MDC_ECG_LEAD_I",
+	"leadII":  "MDC_ECG_LEAD_II",
+	"leadIII": "MDC_ECG_LEAD_III",
+	"aVR":     "MDC_ECG_LEAD_AVR",
+	"aVL":     "MDC_ECG_LEAD_AVL",
+	"aVF":     "MDC_ECG_LEAD_AVF",
+	"v1":      "MDC_ECG_LEAD_V1",
+	"v2":      "MDC_ECG_LEAD_V2",
+	"v3":      "MDC_ECG_LEAD_V3",
+	"v4":      "MDC_ECG_LEAD_V4",
+	"v5":      "MDC_ECG_LEAD_V5",
+	"v6":      "MDC_ECG_LEAD_V6",
+}
+
+type aecgDocument struct {
+	XMLName       xml.Name      `xml:"urn:hl7-org:v3 AnnotatedECG"`
+	XSI           string        `xml:"xmlns:xsi,attr"`
+	ID            aecgID        `xml:"id"`
+	Code          aecgCode      `xml:"code"`
+	EffectiveTime *aecgInterval `xml:"effectiveTime,omitempty"`
+	Series        aecgSeries    `xml:"component>series"`
+}
+
+type aecgID struct {
+	Root       string `xml:"root,attr,omitempty"`
+	NullFlavor string `xml:"nullFlavor,attr,omitempty"`
+}
+
+type aecgCode struct {
+	Code       string `xml:"code,attr"`
+	CodeSystem string `xml:"codeSystem,attr"`
+}
+
+type aecgValue struct {
+	Value string `xml:"value,attr"`
+	Unit  string `xml:"unit,attr,omitempty"`
+}
+
+type aecgInterval struct {
+	Low  aecgValue `xml:"low"`
+	High aecgValue `xml:"high"`
+}
+
+type aecgDevice struct {
+	ID           aecgID `xml:"id"`
+	ModelName    string `xml:"manufacturerModelName,omitempty"`
+	SoftwareName string `xml:"softwareName,omitempty"`
+}
+
+type aecgSeries struct {
+	Code          aecgCode        `xml:"code"`
+	EffectiveTime *aecgInterval   `xml:"effectiveTime,omitempty"`
+	Device        aecgDevice      `xml:"author>seriesAuthor>manufacturedSeriesDevice"`
+	Sequences     []aecgComponent `xml:"component>sequenceSet>component"`
+}
+
+// aecgComponent wraps each sequence in its own component element
+type aecgComponent struct {
+	Sequence aecgSequence `xml:"sequence"`
+}
+
+type aecgSequence struct {
+	Code  aecgCode          `xml:"code"`
+	Value aecgSequenceValue `xml:"value"`
+}
+
+type aecgSequenceValue struct {
+	Type      string     `xml:"xsi:type,attr"`
+	Head      *aecgValue `xml:"head,omitempty"`
+	Increment *aecgValue `xml:"increment,omitempty"`
+	Origin    *aecgValue `xml:"origin,omitempty"`
+	Scale     *aecgValue `xml:"scale,omitempty"`
+	Digits    string     `xml:"digits,omitempty"`
+}
+
+// WriteHL7aECG writes ecg to w as an HL7 v3 annotated ECG document with a single
+// rhythm series holding one sequence per present lead. Samples are written as digits
+// scaled to microvolts. Clinical trial and subject context is left to the caller.
+func WriteHL7aECG(w io.Writer, ecg *EcgData) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+	if ecg.Gain <= 0 || ecg.Frequency <= 0 {
+		return fmt.Errorf("Invalid gain %v or frequency %v", ecg.Gain, ecg.Frequency)
+	}
+
+	doc := &aecgDocument{
+		XSI:  "http://www.w3.org/2001/XMLSchema-instance",
+		ID:   aecgID{NullFlavor: "NI"},
+		Code: aecgCode{Code: "93000", CodeSystem: "2.16.840.1.113883.6.12"},
+		Series: aecgSeries{
+			Code:   aecgCode{Code: "RHYTHM", CodeSystem: hl7ActCodeSystem},
+			Device: aecgDevice{ID: aecgID{NullFlavor: "NI"}},
+		},
+	}
+
+	maxLen := 0
+	for _, lead := range leads {
+		if len(lead.Samples) > maxLen {
+			maxLen = len(lead.Samples)
+		}
+	}
+
+	// Time is absolute when the recording date is known and relative otherwise
+	interval := strconv.FormatFloat(1/float64(ecg.Frequency), 'g', -1, 32)
+	timeSequence := aecgSequence{
+		Code: aecgCode{Code: "TIME_RELATIVE", CodeSystem: hl7ActCodeSystem},
+		Value: aecgSequenceValue{
+			Type:      "GLIST_PQ",
+			Head:      &aecgValue{Value: "0", Unit: "s"},
+			Increment: &aecgValue{Value: interval, Unit: "s"},
+		},
+	}
+
+	if ecg.Info != nil {
+		info := ecg.Info.ToJSON()
+		// HL7 writes UUID roots in uppercase
+		if id := ecg.Info.recordingID(); id != "" {
+			doc.ID = aecgID{Root: strings.ToUpper(id)}
+		}
+		if info.PhoneUDID != "" {
+			doc.Series.Device.ID = aecgID{Root: info.PhoneUDID}
+		}
+		doc.Series.Device.ModelName = info.PhoneModel
+		doc.Series.Device.SoftwareName = info.RecorderSoftware
+
+		if t, err := ecg.Info.RecordedAt(); err == nil {
+			end := t.Add(time.Duration(float64(maxLen) / float64(ecg.Frequency) * float64(time.Second)))
+			doc.EffectiveTime = &aecgInterval{
+				Low:  aecgValue{Value: t.Format(hl7TimeLayout)},
+				High: aecgValue{Value: end.Format(hl7TimeLayout)},
+			}
+			doc.Series.EffectiveTime = doc.EffectiveTime
+
+			timeSequence.Code.Code = "TIME_ABSOLUTE"
+			timeSequence.Value.Type = "GLIST_TS"
+			timeSequence.Value.Head = &aecgValue{Value: t.Format(hl7TimeLayout)}
+		}
+	}
+
+	doc.Series.Sequences = append(doc.Series.Sequences, aecgComponent{timeSequence})
+	for _, lead := range leads {
+		digits := make([]string, len(lead.Samples))
+		for i, s := range lead.Samples {
+			digits[i] = strconv.Itoa(int(s))
+		}
+
+		scale := strconv.FormatFloat(1000/float64(ecg.LeadGain(lead.Name)), 'g', -1, 32)
+		doc.Series.Sequences = append(doc.Series.Sequences, aecgComponent{aecgSequence{
+			Code: aecgCode{Code: aecgLeadCodes[lead.Name], CodeSystem: hl7MDCCodeSystem},
+			Value: aecgSequenceValue{
+				Type:   "SLIST_PQ",
+				Origin: &aecgValue{Value: "0", Unit: "uV"},
+				Scale:  &aecgValue{Value: scale, Unit: "uV"},
+				Digits: strings.Join(digits, " "),
+			},
+		}})
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
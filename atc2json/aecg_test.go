@@ -0,0 +1,108 @@
+package atc2json
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWriteHL7aECG(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "2012-04-03T14:17:43-7:00")
+	copy(info.RecordingUUID[:], "1285733B-9A84-4349-A845-52FCC436353F")
+	copy(info.PhoneModel[:], "iPhone4,1")
+	copy(info.RecorderSoftware[:], "AliveECG v1.6.9.354")
+
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Info:      info,
+		Samples: EcgSamples{
+			LeadI:  []int16{1, -2, 3},
+			LeadII: []int16{4, 5, 6},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteHL7aECG(buf, ecg)
+	assert.Nil(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<AnnotatedECG xmlns="urn:hl7-org:v3" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <id root="1285733B-9A84-4349-A845-52FCC436353F"></id>
+  <code code="93000" codeSystem="2.16.840.1.113883.6.12"></code>
+  <effectiveTime>
+    <low value="20120403141743.000-0700"></low>
+    <high value="20120403141743.010-0700"></high>
+  </effectiveTime>
+  <component>
+    <series>
+      <code code="RHYTHM" codeSystem="2.16.840.1.113883.5.4"></code>
+      <effectiveTime>
+        <low value="20120403141743.000-0700"></low>
+        <high value="20120403141743.010-0700"></high>
+      </effectiveTime>
+      <author>
+        <seriesAuthor>
+          <manufacturedSeriesDevice>
+            <id nullFlavor="NI"></id>
+            <manufacturerModelName>iPhone4,1</manufacturerModelName>
+            <softwareName>AliveECG v1.6.9.354</softwareName>
+          </manufacturedSeriesDevice>
+        </seriesAuthor>
+      </author>
+      <component>
+        <sequenceSet>
+          <component>
+            <sequence>
+              <code code="TIME_ABSOLUTE" codeSystem="2.16.840.1.113883.5.4"></code>
+              <value xsi:type="GLIST_TS">
+                <head value="20120403141743.000-0700"></head>
+                <increment value="0.0033333334" unit="s"></increment>
+              </value>
+            </sequence>
+          </component>
+          <component>
+            <sequence>
+              <code code="MDC_ECG_LEAD_I" codeSystem="2.16.840.1.113883.6.24"></code>
+              <value xsi:type="SLIST_PQ">
+                <origin value="0" unit="uV"></origin>
+                <scale value="0.5" unit="uV"></scale>
+                <digits>1 -2 3</digits>
+              </value>
+            </sequence>
+          </component>
+          <component>
+            <sequence>
+              <code code="MDC_ECG_LEAD_II" codeSystem="2.16.840.1.113883.6.24"></code>
+              <value xsi:type="SLIST_PQ">
+                <origin value="0" unit="uV"></origin>
+                <scale value="0.5" unit="uV"></scale>
+                <digits>4 5 6</digits>
+              </value>
+            </sequence>
+          </component>
+        </sequenceSet>
+      </component>
+    </series>
+  </component>
+</AnnotatedECG>
+`, buf.String())
+}
+
+func TestWriteHL7aECGLeadGains(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		LeadGains: map[string]float32{"leadII": 1000},
+		Samples:   EcgSamples{LeadI: []int16{1}, LeadII: []int16{2}},
+	}
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteHL7aECG(buf, ecg))
+
+	output := buf.String()
+	leadI := output[strings.Index(output, "MDC_ECG_LEAD_I\""):]
+	leadII := output[strings.Index(output, "MDC_ECG_LEAD_II\""):]
+	assert.Contains(t, leadI[:strings.Index(leadI, "</sequence>")], `<scale value="0.5" unit="uV">`)
+	assert.Contains(t, leadII[:strings.Index(leadII, "</sequence>")], `<scale value="1" unit="uV">`)
+}
@@ -0,0 +1,66 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WFDB annotation codes of the MIT format, see wfdb/ecgcodes.h
+const (
+	wfdbSkip = 59
+
+	// wfdbMaxInterval is the largest interval fitting the 10 bit time field
+	wfdbMaxInterval = 0x3ff
+)
+
+// wfdbBeatCodes maps Beat types to their WFDB annotation codes
+var wfdbBeatCodes = map[string]uint16{
+	"N": 1,  // NORMAL
+	"L": 2,  // LBBB
+	"R": 3,  // RBBB
+	"a": 4,  // ABERR
+	"V": 5,  // PVC
+	"F": 6,  // FUSION
+	"J": 7,  // NPC
+	"A": 8,  // APC
+	"S": 9,  // SVPB
+	"E": 10, // VESC
+	"j": 11, // NESC
+	"/": 12, // PACE
+	"Q": 13, // UNKNOWN
+}
+
+// WriteAnnotations writes beats to w as a WFDB annotation file in MIT format, as
+// read by rdann for a .atr file. Each annotation is a little-endian 16 bit word of
+// the annotation code in the top 6 bits and the samples since the previous
+// annotation in the low 10 bits. Longer intervals are preceded by a SKIP word and
+// a 32 bit interval, high half first. Beats must be in sample order.
+func WriteAnnotations(w io.Writer, beats []Beat) error {
+	buf := &bytes.Buffer{}
+	last := 0
+	for _, beat := range beats {
+		code, ok := wfdbBeatCodes[beat.Type]
+		if !ok {
+			return fmt.Errorf("Unsupported beat type %q", beat.Type)
+		}
+		interval := beat.SampleIndex - last
+		if interval < 0 {
+			return fmt.Errorf("Beat at sample %d follows beat at sample %d", beat.SampleIndex, last)
+		}
+		if interval > wfdbMaxInterval {
+			binary.Write(buf, binary.LittleEndian, uint16(wfdbSkip<<10))
+			binary.Write(buf, binary.LittleEndian, uint16(uint32(interval)>>16))
+			binary.Write(buf, binary.LittleEndian, uint16(interval))
+			interval = 0
+		}
+		binary.Write(buf, binary.LittleEndian, code<<10|uint16(interval))
+		last = beat.SampleIndex
+	}
+	// An all-zero word ends the file
+	binary.Write(buf, binary.LittleEndian, uint16(0))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,30 @@
+package atc2json
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWriteAnnotations(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteAnnotations(buf, []Beat{
+		{SampleIndex: 100, Type: BeatNormal},
+		{SampleIndex: 400, Type: "V"},
+		{SampleIndex: 2000, Type: BeatNormal},
+		{SampleIndex: 70000 + 2000, Type: BeatNormal},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x64, 0x04,
+		0x2c, 0x15,
+		0x00, 0xec, 0x00, 0x00, 0x40, 0x06, 0x00, 0x04,
+		0x00, 0xec, 0x01, 0x00, 0x70, 0x11, 0x00, 0x04,
+		0x00, 0x00,
+	}, buf.Bytes())
+
+	err = WriteAnnotations(&bytes.Buffer{}, []Beat{{SampleIndex: 5, Type: "X"}})
+	assert.EqualError(t, err, `Unsupported beat type "X"`)
+	err = WriteAnnotations(&bytes.Buffer{}, []Beat{{SampleIndex: 5, Type: "N"}, {SampleIndex: 4, Type: "N"}})
+	assert.EqualError(t, err, "Beat at sample 4 follows beat at sample 5")
+}
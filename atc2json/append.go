@@ -0,0 +1,53 @@
+package atc2json
+
+import "fmt"
+
+// Append returns a recording holding the samples of b after those of a, as when a
+// long capture was split into two files. Both must have the same Frequency, Gain
+// and set of present leads. The metadata, including Info, is taken from a and the
+// fields set by Convert are left empty. Neither a nor b is modified.
+func Append(a, b *EcgData) (*EcgData, error) {
+	if a.Frequency != b.Frequency {
+		return nil, fmt.Errorf("Frequency mismatch: %v Hz and %v Hz", a.Frequency, b.Frequency)
+	}
+	if a.Gain != b.Gain {
+		return nil, fmt.Errorf("Gain mismatch: %v and %v", a.Gain, b.Gain)
+	}
+
+	result := copyMetadata(a)
+	aLeads, bLeads := a.Samples.leadRefs(), b.Samples.leadRefs()
+	for i, ref := range result.Samples.leadRefs() {
+		first, second := *aLeads[i], *bLeads[i]
+		if (first == nil) != (second == nil) {
+			return nil, fmt.Errorf("Lead %s is present in only one recording", leadDefinitions[i].Name)
+		}
+		if first == nil {
+			continue
+		}
+		lead := make([]int16, 0, len(first)+len(second))
+		lead = append(lead, first...)
+		*ref = append(lead, second...)
+	}
+	return result, nil
+}
+
+// copyMetadata returns a recording with the metadata parsed from the header, fmt
+// and info blocks of ecg and no samples
+func copyMetadata(ecg *EcgData) *EcgData {
+	return &EcgData{
+		Frequency:           ecg.Frequency,
+		AmplitudeResolution: ecg.AmplitudeResolution,
+		MainsFrequency:      ecg.MainsFrequency,
+		Gain:                ecg.Gain,
+		LeadGains:           ecg.LeadGains,
+		Format:              ecg.Format,
+		Flags:               ecg.Flags,
+		FmtFlags:            ecg.FmtFlags,
+		FmtReserved:         ecg.FmtReserved,
+		FmtExtra:            ecg.FmtExtra,
+		FileVersion:         ecg.FileVersion,
+		BlockOrder:          ecg.BlockOrder,
+		SignatureSubtype:    ecg.SignatureSubtype,
+		Info:                ecg.Info,
+	}
+}
@@ -0,0 +1,47 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	half := len(ecg.Samples.LeadI) / 2
+	first, second := *ecg, *ecg
+	first.Samples = EcgSamples{LeadI: ecg.Samples.LeadI[:half]}
+	second.Samples = EcgSamples{LeadI: ecg.Samples.LeadI[half:]}
+
+	joined, err := Append(&first, &second)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg.Samples.LeadI, joined.Samples.LeadI)
+	assert.Equal(t, ecg.Info, joined.Info)
+	assert.Equal(t, ecg.Frequency, joined.Frequency)
+	assert.Len(t, first.Samples.LeadI, half)
+
+	// The stitched recording encodes back to the original
+	encoded, err := Encode(joined)
+	assert.Nil(t, err)
+	reparsed, err := Parse(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg.Samples, reparsed.Samples)
+
+	second.Frequency = 500
+	_, err = Append(&first, &second)
+	assert.EqualError(t, err, "Frequency mismatch: 300 Hz and 500 Hz")
+
+	second.Frequency = first.Frequency
+	second.Gain = 1000
+	_, err = Append(&first, &second)
+	assert.EqualError(t, err, "Gain mismatch: 2000 and 1000")
+
+	second.Gain = first.Gain
+	second.Samples.LeadII = []int16{1}
+	_, err = Append(&first, &second)
+	assert.EqualError(t, err, "Lead leadII is present in only one recording")
+}
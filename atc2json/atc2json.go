@@ -2,26 +2,115 @@ package atc2json
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
 )
 
 var AtcFileSignature = [8]byte{'A', 'L', 'I', 'V', 'E', 0, 0, 0}
 
+// AtcSignaturePrefix starts the signature of every ATC file. The bytes after it are
+// zero in AtcFileSignature but hold a sub-version marker in some version 3 files,
+// so only the prefix is checked.
+const AtcSignaturePrefix = "ALIVE"
+
 const ChecksumLength = 4
 
+// maxBlockPrealloc bounds the bytes allocated for a block body before its data has
+// been read, larger bodies grow as they are read
+const maxBlockPrealloc = 1 << 20
+
+// GainUnitLSBPerMillivolt is the unit of EcgData.Gain, sample counts per millivolt
+const GainUnitLSBPerMillivolt = "LSB/mV"
+
+// AtcFileHeader starts every ATC file. All versions share the block layout parsed
+// here, but their block semantics differ:
+//
+//   - Version 2 block checksums are the sum of the block bytes as unsigned values.
+//   - Version 4 block checksums sum the bytes as signed values, so blocks holding
+//     bytes of 0x80 and above fail verification unless SkipChecksumErrors is set,
+//     and files carry an "ann " block that is treated as unknown.
+//
+// Use ParseOptions.MaxSupportedVersion to reject versions a caller has not checked.
 type AtcFileHeader struct {
 	FileSignature [8]byte
 	FileVersion   uint32
 }
 
+// SignatureSubtype returns the signature bytes after AtcSignaturePrefix as hex,
+// or "" when they are zero as in AtcFileSignature
+func (h *AtcFileHeader) SignatureSubtype() string {
+	subtype := h.FileSignature[len(AtcSignaturePrefix):]
+	if bytes.Equal(subtype, AtcFileSignature[len(AtcSignaturePrefix):]) {
+		return ""
+	}
+	return hex.EncodeToString(subtype)
+}
+
+// hasAtcSignature reports whether data starts with AtcSignaturePrefix
+func hasAtcSignature(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(AtcSignaturePrefix))
+}
+
 type BlockHeader struct {
 	BlockId [4]byte
 	Length  uint32
 }
 
+// SampleFormat is the sample encoding given by FmtBlock.Format
+type SampleFormat int
+
+// Known values of FmtBlock.Format
+const (
+	// SampleFormatRaw samples are stored as little-endian int16 values
+	SampleFormatRaw SampleFormat = 1
+	// SampleFormatDelta samples are stored as little-endian int16 first differences,
+	// the first value being the difference from zero
+	SampleFormatDelta SampleFormat = 2
+)
+
+// String returns "raw" or "delta", or "unknown(0xNN)" for other values
+func (f SampleFormat) String() string {
+	switch f {
+	case SampleFormatRaw:
+		return "raw"
+	case SampleFormatDelta:
+		return "delta"
+	default:
+		return fmt.Sprintf("unknown(0x%02x)", int(f))
+	}
+}
+
+// DuplicateLeadMode selects what parsing does when a lead block appears more than once
+type DuplicateLeadMode int
+
+const (
+	// DuplicateLeadOverwrite keeps the samples of the last block of a lead
+	DuplicateLeadOverwrite DuplicateLeadMode = iota
+	// DuplicateLeadAppend concatenates the blocks of a lead in file order, for
+	// segmented captures. Delta samples are decoded per block.
+	DuplicateLeadAppend
+	// DuplicateLeadError fails parsing at the second block of a lead
+	DuplicateLeadError
+)
+
+// Known bits of FmtBlock.Flags
+const (
+	// FmtFlagEnhancedFilter is set by devices that applied their enhanced filter
+	// to the samples
+	FmtFlagEnhancedFilter = 0x01
+	// FmtFlagMains60Hz is set when the mains frequency is 60 Hz rather than 50 Hz
+	FmtFlagMains60Hz = 0x02
+)
+
 type FmtBlock struct {
 	Format     byte
 	Frequency  uint16
@@ -30,6 +119,23 @@ type FmtBlock struct {
 	Reserved   uint16
 }
 
+// decodeFmtBlock decodes the FmtBlock at the start of body and returns any bytes
+// after it, as written by firmware with a longer fmt block
+func decodeFmtBlock(body []byte, order binary.ByteOrder) (*FmtBlock, []byte, error) {
+	fmtBlock := &FmtBlock{}
+	size := binary.Size(fmtBlock)
+	if len(body) < size {
+		return nil, nil, fmt.Errorf("Invalid fmt block: length %d, expected at least %d", len(body), size)
+	}
+
+	binary.Read(bytes.NewReader(body[:size]), order, fmtBlock)
+	var extra []byte
+	if len(body) > size {
+		extra = append([]byte{}, body[size:]...)
+	}
+	return fmtBlock, extra, nil
+}
+
 // InfoBlock contains the ATC info block header
 type InfoBlock struct {
 	DateRecorded     [32]byte
@@ -41,15 +147,169 @@ type InfoBlock struct {
 	Location         [52]byte
 }
 
+// InfoBlockJSON is the human-readable form of InfoBlock used in JSON output
+type InfoBlockJSON struct {
+	DateRecorded     string `json:"dateRecorded"`
+	RecordedAt       string `json:"recordedAt,omitempty"`
+	RecordingUUID    string `json:"recordingUUID"`
+	UUID             string `json:"uuid,omitempty"`
+	PhoneUDID        string `json:"phoneUDID"`
+	PhoneModel       string `json:"phoneModel"`
+	RecorderSoftware string `json:"recorderSoftware"`
+	RecorderHardware string `json:"recorderHardware"`
+	Location         string `json:"location"`
+}
+
+// ToJSON converts the fixed-size byte fields of the info block to trimmed strings
+func (info InfoBlock) ToJSON() InfoBlockJSON {
+	var recordedAt string
+	if t, err := info.RecordedAt(); err == nil {
+		recordedAt = t.Format(time.RFC3339)
+	}
+	uuid, _ := info.UUID()
+
+	return InfoBlockJSON{
+		DateRecorded:     infoString(info.DateRecorded[:]),
+		RecordedAt:       recordedAt,
+		RecordingUUID:    infoString(info.RecordingUUID[:]),
+		UUID:             uuid,
+		PhoneUDID:        infoString(info.PhoneUDID[:]),
+		PhoneModel:       infoString(info.PhoneModel[:]),
+		RecorderSoftware: infoString(info.RecorderSoftware[:]),
+		RecorderHardware: infoString(info.RecorderHardware[:]),
+		Location:         infoString(info.Location[:]),
+	}
+}
+
+// MarshalJSON emits the info block as InfoBlockJSON instead of raw byte arrays
+func (info InfoBlock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(info.ToJSON())
+}
+
+// UnmarshalJSON reads the InfoBlockJSON written by MarshalJSON back into the
+// NUL padded byte fields. RecordedAt and UUID are ignored in favour of DateRecorded
+// and RecordingUUID.
+func (info *InfoBlock) UnmarshalJSON(data []byte) error {
+	var fields InfoBlockJSON
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	parsed := InfoBlock{}
+	for _, field := range []struct {
+		name  string
+		dst   []byte
+		value string
+	}{
+		{"dateRecorded", parsed.DateRecorded[:], fields.DateRecorded},
+		{"recordingUUID", parsed.RecordingUUID[:], fields.RecordingUUID},
+		{"phoneUDID", parsed.PhoneUDID[:], fields.PhoneUDID},
+		{"phoneModel", parsed.PhoneModel[:], fields.PhoneModel},
+		{"recorderSoftware", parsed.RecorderSoftware[:], fields.RecorderSoftware},
+		{"recorderHardware", parsed.RecorderHardware[:], fields.RecorderHardware},
+		{"location", parsed.Location[:], fields.Location},
+	} {
+		if len(field.value) > len(field.dst) {
+			return fmt.Errorf("Info field %s is %d bytes, longer than %d", field.name, len(field.value), len(field.dst))
+		}
+		copy(field.dst, field.value)
+	}
+
+	*info = parsed
+	return nil
+}
+
 type EcgData struct {
-	Frequency           float32    `json:"frequency"`
-	AmplitudeResolution int        `json:"amplitudeResolution"`
-	MainsFrequency      int        `json:"mainsFrequency"`
-	Gain                float32    `json:"gain"`
-	Samples             EcgSamples `json:"samples"`
-	Info                *InfoBlock
+	Frequency           float32 `json:"frequency"`
+	AmplitudeResolution int     `json:"amplitudeResolution"`
+	MainsFrequency      int     `json:"mainsFrequency"`
+	Gain                float32 `json:"gain"`
+	// LeadGains holds the gain of the leads an extended fmt block gives their own
+	// amplitude resolution when ParseOptions.LeadResolutions is set, see LeadGain.
+	// A GainOverride applies to every lead.
+	LeadGains map[string]float32 `json:"leadGains,omitempty"`
+	// GainUnit is the unit of Gain, GainUnitLSBPerMillivolt, set by Convert
+	GainUnit string `json:"gainUnit,omitempty"`
+	// MicrovoltsPerLSB is the inverse of Gain, the microvolts per sample count, set by Convert
+	MicrovoltsPerLSB float64      `json:"microvoltsPerLsb,omitempty"`
+	Format           SampleFormat `json:"format"`
+	// Flags is the raw flags byte of the fmt block, see the FmtFlag constants
+	Flags int `json:"flags"`
+	// FmtFlags is Flags decoded, see DecodeFmtFlags. Encode only uses Flags.
+	FmtFlags FmtFlags `json:"fmtFlags"`
+	// FmtReserved is the raw reserved field of the fmt block
+	FmtReserved int `json:"fmtReserved"`
+	// FmtExtra holds the bytes of a fmt block longer than FmtBlock
+	FmtExtra []byte `json:"fmtExtra,omitempty"`
+	// FileVersion is the version from the file header, see AtcFileHeader
+	FileVersion int `json:"fileVersion"`
+	// SignatureSubtype is AtcFileHeader.SignatureSubtype, the hex sub-version marker
+	// some files carry in their signature
+	SignatureSubtype string              `json:"signatureSubtype,omitempty"`
+	Samples          EcgSamples          `json:"samples"`
+	Info             *InfoBlock          `json:"Info"`
+	LeadInfo         map[string]LeadInfo `json:"leadInfo,omitempty"`
+	// The fields below up to InvertedLeads are only set by Analyze
+	// DetectedMainsFrequency is the interference found by DetectMainsFrequency
+	DetectedMainsFrequency int `json:"detectedMainsFrequency,omitempty"`
+	// DurationSeconds is Duration in seconds
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+	// Stats holds the amplitude statistics of every lead
+	Stats        map[string]LeadStats `json:"stats,omitempty"`
+	HeartRateBpm float64              `json:"heartRateBpm,omitempty"`
+	Beats        []Beat               `json:"beats,omitempty"`
+	// LeadOff lists where an electrode was disconnected, see LeadOffIntervals
+	LeadOff []Interval `json:"leadOff,omitempty"`
+	// ContentSHA256 is the digest returned by ContentHash
+	ContentSHA256 string `json:"contentHash,omitempty"`
+	// InvertedLeads lists the leads negated by CorrectInversion
+	InvertedLeads []string `json:"invertedLeads,omitempty"`
+	// UnknownBlocks holds unrecognized blocks in file order when ParseOptions.KeepUnknownBlocks is set
+	UnknownBlocks []RawBlock `json:"unknownBlocks,omitempty"`
+	// BlockOrder lists the ids of the info, fmt, lead and kept unknown blocks in file
+	// order, so Encode can reproduce it. It is only set when the file differs from
+	// the default order of Encode.
+	BlockOrder []string `json:"blockOrder,omitempty"`
+	// RawBlocks holds every block in file order when ParseOptions.IncludeRawBlocks is set
+	RawBlocks []AuditBlock `json:"rawBlocks,omitempty"`
+	// FileChecksumVerified is set when the file ends with a whole-file checksum that matched
+	FileChecksumVerified bool `json:"fileChecksumVerified,omitempty"`
+	// Warnings lists the soft issues found while parsing, in the order found
+	Warnings []Warning `json:"warnings,omitempty"`
+
+	// parse is the state of the block handlers, only set while parsing
+	parse *parseState
 }
 
+// IsMains60Hz reports whether the fmt block flags mark 60 Hz mains
+func (ecg *EcgData) IsMains60Hz() bool {
+	return ecg.Flags&FmtFlagMains60Hz != 0
+}
+
+// RawBlock is a block this package does not interpret
+type RawBlock struct {
+	ID   string `json:"id"`
+	Data []byte `json:"data"`
+}
+
+// AuditBlock is the raw body of a block and the checksum stored after it, as read
+// from the file at Offset, the position of its block header
+type AuditBlock struct {
+	ID       string `json:"id"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// LeadInfo summarizes the length of a single lead
+type LeadInfo struct {
+	SampleCount int     `json:"sampleCount"`
+	Duration    float32 `json:"duration"`
+}
+
+// EcgSamples holds the samples of each lead. A lead is nil when its block is
+// absent or empty, so a non-nil lead always has samples.
 type EcgSamples struct {
 	LeadI   []int16 `json:"leadI"`
 	LeadII  []int16 `json:"leadII,omitempty"`
@@ -57,230 +317,676 @@ type EcgSamples struct {
 	AVR     []int16 `json:"aVR,omitempty"`
 	AVL     []int16 `json:"aVL,omitempty"`
 	AVF     []int16 `json:"aVF,omitempty"`
+	V1      []int16 `json:"v1,omitempty"`
+	V2      []int16 `json:"v2,omitempty"`
+	V3      []int16 `json:"v3,omitempty"`
+	V4      []int16 `json:"v4,omitempty"`
+	V5      []int16 `json:"v5,omitempty"`
+	V6      []int16 `json:"v6,omitempty"`
+}
+
+// leadDefinition ties a lead's block id to its JSON key and conventional label
+type leadDefinition struct {
+	BlockID string
+	Name    string
+	Label   string
+}
+
+// leadDefinitions lists every supported lead in file order. The order matches
+// the fields of EcgSamples and the slices returned by leadRefs.
+var leadDefinitions = []leadDefinition{
+	// Space after word is intended, per spec - cp 2019-2-19
+	{"ecg ", "leadI", "I"},
+	{"ecg2", "leadII", "II"},
+	{"ecg3", "leadIII", "III"},
+	{"ecg4", "aVR", "aVR"},
+	{"ecg5", "aVL", "aVL"},
+	{"ecg6", "aVF", "aVF"},
+	{"ecg7", "v1", "V1"},
+	{"ecg8", "v2", "V2"},
+	{"ecg9", "v3", "V3"},
+	{"ec10", "v4", "V4"},
+	{"ec11", "v5", "V5"},
+	{"ec12", "v6", "V6"},
+}
+
+// Lead is a single named lead of samples
+type Lead struct {
+	Name    string
+	Samples []int16
+}
+
+// leadRefs returns pointers to every lead slice in leadDefinitions order
+func (s *EcgSamples) leadRefs() []*[]int16 {
+	return []*[]int16{
+		&s.LeadI, &s.LeadII, &s.LeadIII, &s.AVR, &s.AVL, &s.AVF,
+		&s.V1, &s.V2, &s.V3, &s.V4, &s.V5, &s.V6,
+	}
+}
+
+// Leads returns the present (non-nil) leads in file order, named after their JSON keys
+func (s *EcgSamples) Leads() []Lead {
+	var leads []Lead
+	for i, ref := range s.leadRefs() {
+		if *ref != nil {
+			leads = append(leads, Lead{Name: leadDefinitions[i].Name, Samples: *ref})
+		}
+	}
+	return leads
 }
 
+// Lead returns the samples of the lead with the given name or label, e.g. "leadII"
+// or "II", or nil if it is not present
+func (s *EcgSamples) Lead(name string) []int16 {
+	for i, ref := range s.leadRefs() {
+		if leadDefinitions[i].Name == name || leadDefinitions[i].Label == name {
+			return *ref
+		}
+	}
+	return nil
+}
+
+// Lead returns the samples of the lead with the given name or label, see
+// EcgSamples.Lead, and whether the lead is present
+func (ecg *EcgData) Lead(name string) ([]int16, bool) {
+	samples := ecg.Samples.Lead(name)
+	return samples, samples != nil
+}
+
+// shortLeadName returns the conventional lead label for a lead name, e.g. "II" for "leadII"
+func shortLeadName(name string) string {
+	for _, def := range leadDefinitions {
+		if def.Name == name {
+			return def.Label
+		}
+	}
+	return name
+}
+
+// FileChecksumBlockID is the BlockID reported in a ChecksumError for the optional
+// whole-file checksum. That checksum is the sum of every byte before it, including
+// the file header and the per-block checksums, which are still verified on their own.
+const FileChecksumBlockID = "file"
+
+// ParseOptions controls how strictly ParseWithOptions treats a damaged file
+type ParseOptions struct {
+	// SkipChecksumErrors drops blocks whose checksum does not match instead of failing
+	SkipChecksumErrors bool
+	// KeepUnknownBlocks stores unrecognized blocks in EcgData.UnknownBlocks instead of discarding them
+	KeepUnknownBlocks bool
+	// StrictLeadLength fails when the present leads do not all have the same number of samples
+	StrictLeadLength bool
+	// GainOverride replaces the gain derived from the fmt block, for files with a
+	// known bad amplitude resolution. AmplitudeResolution is updated to match.
+	GainOverride *float32
+	// FrequencyOverride replaces the sampling frequency of the fmt block
+	FrequencyOverride *float32
+	// ReturnPartial returns the EcgData built from the blocks read before a truncated
+	// or otherwise unreadable block alongside the error, provided the fmt block was
+	// among them. The damaged block and everything after it are dropped.
+	ReturnPartial bool
+	// MillivoltDecimals is the number of decimal places of the samples written by
+	// ConvertMillivoltsWithOptions, 0 writing whole millivolts. Nil means
+	// DefaultMillivoltDecimals and a negative value keeps the full float32 precision.
+	MillivoltDecimals *int
+	// UnitMode selects the sample units written by ConvertWithOptions, defaults to UnitRaw
+	UnitMode UnitMode
+	// Analyze makes ConvertWithOptions include the fields set by EcgData.Analyze
+	// when writing sample counts
+	Analyze bool
+	// Indent makes EncodeWithOptions indent the JSON with this string per level
+	Indent string
+	// MaxSupportedVersion fails with an UnsupportedVersionError when the file
+	// version is newer. Zero accepts every version.
+	MaxSupportedVersion uint32
+	// ByteOrder of the numeric header fields, checksums and samples, defaults to
+	// binary.LittleEndian. Signatures and block ids are byte strings and unaffected.
+	ByteOrder binary.ByteOrder
+	// OnDuplicateLead selects what happens when a lead block appears more than
+	// once, defaults to DuplicateLeadOverwrite
+	OnDuplicateLead DuplicateLeadMode
+	// IncludeRawBlocks stores the body and stored checksum of every block read in
+	// EcgData.RawBlocks, so the decoding can be audited without the original file
+	IncludeRawBlocks bool
+	// LeadResolutions reads per-lead amplitude resolutions from the bytes past
+	// FmtBlock in an extended fmt block into EcgData.LeadGains. Nothing in the file
+	// marks that layout, so only set it for files known to use it, see the README.
+	LeadResolutions bool
+}
+
+// byteOrder returns ByteOrder, defaulting to binary.LittleEndian
+func (opts ParseOptions) byteOrder() binary.ByteOrder {
+	if opts.ByteOrder == nil {
+		return binary.LittleEndian
+	}
+	return opts.ByteOrder
+}
+
+// UnitMode selects the units of the samples written by ConvertWithOptions
+type UnitMode int
+
+const (
+	// UnitRaw writes sample counts, as Convert
+	UnitRaw UnitMode = iota
+	// UnitMicrovolts writes integer microvolts, as ConvertMicrovolts
+	UnitMicrovolts
+	// UnitMillivolts writes millivolts, as ConvertMillivoltsWithOptions
+	UnitMillivolts
+)
+
 // Parse will take atcData and return EcgData struct with error
 func Parse(atcData []byte) (*EcgData, error) {
+	return ParseReader(bytes.NewReader(atcData))
+}
+
+// ParseReader reads an ATC file block by block from r and returns EcgData struct with error
+func ParseReader(r io.Reader) (*EcgData, error) {
+	ecgData, _, err := ParseReaderWithOptions(r, ParseOptions{})
+	return ecgData, err
+}
+
+// ParseWithOptions parses atcData using opts. Blocks skipped because of a bad checksum
+// are returned alongside the EcgData built from the remaining blocks.
+func ParseWithOptions(atcData []byte, opts ParseOptions) (*EcgData, []ChecksumError, error) {
+	return ParseReaderWithOptions(bytes.NewReader(atcData), opts)
+}
+
+// ParseReaderWithOptions is the streaming form of ParseWithOptions.
+// Gzip compressed input is decompressed transparently.
+func ParseReaderWithOptions(input io.Reader, opts ParseOptions) (*EcgData, []ChecksumError, error) {
+	return ParseReaderContext(context.Background(), input, opts)
+}
+
+// ParseReaderContext is ParseReaderWithOptions that stops with ctx.Err() once ctx is done.
+// The context is checked between blocks and between chunks of large sample blocks.
+func ParseReaderContext(ctx context.Context, input io.Reader, opts ParseOptions) (*EcgData, []ChecksumError, error) {
+	input, gz, err := decompress(&contextReader{ctx: ctx, r: input})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+
+	ecgData, checksumErrors, err := parseBlocks(ctx, input, opts)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	} else if err != nil && gz != nil && gz.err != nil {
+		err = &DecompressionError{Err: gz.err}
+	}
+	return ecgData, checksumErrors, err
+}
 
-	dataLen := len(atcData)
-	reader := bytes.NewReader(atcData)
+func parseBlocks(ctx context.Context, input io.Reader, opts ParseOptions) (*EcgData, []ChecksumError, error) {
+	r := &countingReader{r: input}
+
+	// The whole-file checksum is built from the per-block sums rather than
+	// summing every byte a second time
+	fileSum := newChecksum()
+	checksumReader := io.TeeReader(r, fileSum)
+
+	order := opts.byteOrder()
 
 	header := AtcFileHeader{}
-	binary.Read(reader, binary.LittleEndian, &header)
+	binary.Read(checksumReader, order, &header)
 
-	if header.FileSignature != AtcFileSignature {
-		return nil, fmt.Errorf("Wrong file signature")
+	if !hasAtcSignature(header.FileSignature[:]) {
+		return nil, nil, fmt.Errorf("Wrong file signature")
+	}
+	if opts.MaxSupportedVersion != 0 && header.FileVersion > opts.MaxSupportedVersion {
+		return nil, nil, &UnsupportedVersionError{Version: header.FileVersion, MaxSupported: opts.MaxSupportedVersion}
 	}
 
 	blockHeader := BlockHeader{}
+	sum := newChecksum()
 
-	var leadISamples []int16
-	var leadIISamples []int16
-	var leadIIISamples []int16
-	var aVRSamples []int16
-	var aVLSamples []int16
-	var aVFSamples []int16
-	var fmtBlock *FmtBlock
-	var infoBlock *InfoBlock
+	state := &parseState{opts: opts, order: order, leadSegments: map[string][][]int16{}}
+	result := &EcgData{FileVersion: int(header.FileVersion), SignatureSubtype: header.SignatureSubtype(), parse: state}
+	var checksumErrors []ChecksumError
 
-	for {
-		blockStart := int64(dataLen - reader.Len())
-
-		err := binary.Read(reader, binary.LittleEndian, &blockHeader)
+	isLead := map[string]bool{}
+	for _, def := range leadDefinitions {
+		isLead[def.BlockID] = true
+	}
 
+	// readErr stops reading at a damaged block, keeping the blocks read before it
+	var readErr error
+	for {
+		err := ctx.Err()
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, fmt.Errorf("Error reading file: %s", err.Error())
+			return nil, checksumErrors, err
 		}
 
-		blockType := string(blockHeader.BlockId[:])
+		sum.Reset()
+		blockStart := r.n
+		block := io.TeeReader(r, sum)
 
-		switch blockType {
-		// Space after word is intended, per spec - cp 2019-2-19
-		case "fmt ":
-			fmtBlock = &FmtBlock{}
-			err = binary.Read(reader, binary.LittleEndian, fmtBlock)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
-			}
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
-			}
+		var headerBuf [8]byte
+		n, err := io.ReadFull(block, headerBuf[:])
 
-		case "info":
-			infoBlock = &InfoBlock{}
-			err = binary.Read(reader, binary.LittleEndian, infoBlock)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
+		if err == io.ErrUnexpectedEOF && n == ChecksumLength {
+			// Some producers append a checksum of the whole file after the last block
+			err = verifyFileChecksum(headerBuf[:ChecksumLength], order, blockStart, fileSum.Sum32())
+			if err == nil {
+				result.FileChecksumVerified = true
+				break
 			}
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
+			if opts.SkipChecksumErrors {
+				checksumErrors = append(checksumErrors, *err.(*ChecksumError))
+				break
 			}
+			readErr = err
+			break
+		}
 
-		// Space after word is intended, per spec - cp 2019-2-19
-		case "ecg ":
-			leadISamples = make([]int16, blockHeader.Length/2)
-			err = binary.Read(reader, binary.LittleEndian, &leadISamples)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
+		if err != nil {
+			if err == io.EOF {
+				break
 			}
+			readErr = fmt.Errorf("Error reading file: %s", err.Error())
+			break
+		}
 
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
-			}
+		binary.Read(bytes.NewReader(headerBuf[:]), order, &blockHeader)
 
-		case "ecg2":
-			leadIISamples = make([]int16, blockHeader.Length/2)
-			err = binary.Read(reader, binary.LittleEndian, &leadIISamples)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
-			}
+		blockType := string(blockHeader.BlockId[:])
+		body := io.LimitReader(block, int64(blockHeader.Length))
 
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
+		var rawBody *bytes.Buffer
+		if opts.IncludeRawBlocks {
+			rawBody = &bytes.Buffer{}
+			body = io.TeeReader(body, rawBody)
+		}
+		var checksum uint32
+		recordRaw := func() {
+			if rawBody != nil {
+				result.RawBlocks = append(result.RawBlocks, AuditBlock{ID: blockType, Offset: blockStart, Data: rawBody.Bytes(), Checksum: checksum})
 			}
+		}
 
-		case "ecg3":
-			leadIIISamples = make([]int16, blockHeader.Length/2)
-			err = binary.Read(reader, binary.LittleEndian, &leadIIISamples)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
+		handler, handled := lookupBlockHandler(blockType)
+		if !handled && !opts.KeepUnknownBlocks {
+			// Unknown blocks are skipped without verifying their checksum
+			_, err = io.CopyN(ioutil.Discard, body, int64(blockHeader.Length))
+			if err == nil {
+				fileSum.add(sum)
+				err = readBlockChecksum(checksumReader, order, &checksum)
 			}
-
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
 			if err != nil {
-				return nil, err
+				readErr = fmt.Errorf("Error reading input: %s", err.Error())
+				break
 			}
+			recordRaw()
+			result.warn(WarningUnknownBlock, "Skipped unknown %q block at offset %d", blockType, blockStart)
+			continue
+		}
 
-		case "ecg4":
-			aVRSamples = make([]int16, blockHeader.Length/2)
-			err = binary.Read(reader, binary.LittleEndian, &aVRSamples)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
-			}
+		data, err := readBytes(body, blockHeader.Length)
+		if err == io.ErrUnexpectedEOF && isLead[blockType] {
+			readErr = &TruncatedBlockError{BlockID: blockType, Expected: int(blockHeader.Length / 2), Got: len(data) / 2}
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("Error reading buffer: %s", err.Error())
+			break
+		}
 
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
+		// Blocks are only decoded once the checksum is verified
+		fileSum.add(sum)
+		err = readBlockChecksum(checksumReader, order, &checksum)
+		if err != nil {
+			readErr = fmt.Errorf("Error reading input: %s", err.Error())
+			break
+		}
+		recordRaw()
+		err = verifyChecksum(checksum, blockType, blockStart, sum)
+		if err != nil {
+			checksumErr, ok := err.(*ChecksumError)
+			if ok && opts.SkipChecksumErrors {
+				checksumErrors = append(checksumErrors, *checksumErr)
+				result.warn(WarningChecksumSkipped, "Skipped %s", checksumErr.Error())
+				continue
 			}
+			readErr = err
+			break
+		}
 
-		case "ecg5":
-			aVLSamples = make([]int16, blockHeader.Length/2)
-			err = binary.Read(reader, binary.LittleEndian, &aVLSamples)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
-			}
+		state.blockOrder = append(state.blockOrder, blockType)
+		if !handled {
+			result.UnknownBlocks = append(result.UnknownBlocks, RawBlock{ID: blockType, Data: data})
+			continue
+		}
+		state.blockStart = blockStart
+		err = handler(data, result)
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	result.parse = nil
 
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
-			}
+	fmtBlock := state.fmtBlock
+	if readErr != nil && (!opts.ReturnPartial || fmtBlock == nil) {
+		return nil, checksumErrors, readErr
+	}
 
-		case "ecg6":
-			aVFSamples = make([]int16, blockHeader.Length/2)
-			err = binary.Read(reader, binary.LittleEndian, &aVFSamples)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading buffer: %s", err.Error())
-			}
+	if fmtBlock == nil {
+		return nil, checksumErrors, fmt.Errorf("Missing fmt block")
+	}
 
-			err = verifyChecksum(atcData, blockStart, blockHeader.Length, reader)
-			if err != nil {
-				return nil, err
-			}
-		default:
-			discardBuf := make([]byte, blockHeader.Length+ChecksumLength)
-			_, err = reader.Read(discardBuf)
-			if err != nil {
-				return nil, fmt.Errorf("Error reading input: %s", err.Error())
+	if opts.GainOverride != nil && *opts.GainOverride <= 0 {
+		return nil, checksumErrors, fmt.Errorf("Invalid gain override: %v", *opts.GainOverride)
+	}
+	if opts.FrequencyOverride != nil && *opts.FrequencyOverride <= 0 {
+		return nil, checksumErrors, fmt.Errorf("Invalid frequency override: %v", *opts.FrequencyOverride)
+	}
+
+	if fmtBlock.Resolution == 0 && opts.GainOverride == nil {
+		return nil, checksumErrors, fmt.Errorf("Invalid fmt block: amplitude resolution is 0")
+	}
+
+	switch SampleFormat(fmtBlock.Format) {
+	case SampleFormatRaw:
+	case SampleFormatDelta:
+		// The fmt block may follow the ecg blocks, so samples are only decoded once all blocks are read
+		for _, segments := range state.leadSegments {
+			for _, segment := range segments {
+				decodeDelta(segment)
 			}
 		}
+	default:
+		return nil, checksumErrors, fmt.Errorf("Unsupported sample format: %s", SampleFormat(fmtBlock.Format))
 	}
 
-	result := &EcgData{}
+	for i, ref := range result.Samples.leadRefs() {
+		segments := state.leadSegments[leadDefinitions[i].BlockID]
+		if len(segments) == 0 {
+			continue
+		}
+		lead := segments[0]
+		for _, segment := range segments[1:] {
+			lead = append(lead, segment...)
+		}
+		*ref = lead
+	}
 
 	result.Gain = 1e6 / float32(fmtBlock.Resolution)
 
 	result.Frequency = float32(fmtBlock.Frequency)
 	result.AmplitudeResolution = int(fmtBlock.Resolution)
+	result.Format = SampleFormat(fmtBlock.Format)
 
-	if fmtBlock.Flags&2 != 0 {
+	if opts.GainOverride != nil {
+		result.Gain = *opts.GainOverride
+		result.AmplitudeResolution = int(math.Round(1e6 / float64(result.Gain)))
+	} else if opts.LeadResolutions {
+		result.LeadGains = decodeLeadGains(result.FmtExtra, order, &result.Samples)
+	}
+	if opts.FrequencyOverride != nil {
+		result.Frequency = *opts.FrequencyOverride
+	}
+
+	result.Flags = int(fmtBlock.Flags)
+	result.FmtFlags = DecodeFmtFlags(fmtBlock.Flags)
+	result.FmtReserved = int(fmtBlock.Reserved)
+
+	if result.FmtFlags.MainsIs60Hz {
 		result.MainsFrequency = 60
 	} else {
 		result.MainsFrequency = 50
 	}
 
-	if leadISamples != nil {
-		result.Samples.LeadI = leadISamples
+	if opts.StrictLeadLength {
+		err := checkLeadLengths(&result.Samples)
+		if err != nil {
+			return nil, checksumErrors, err
+		}
 	}
-
-	if leadIISamples != nil {
-		result.Samples.LeadII = leadIISamples
+	for _, mismatch := range leadLengthMismatches(&result.Samples) {
+		result.warn(WarningLeadLength, "%s", mismatch)
+	}
+	if result.Gain < MinPlausibleGain || result.Gain > MaxPlausibleGain {
+		result.warn(WarningGain, "Gain %v LSB/mV is outside the plausible range %d to %d", result.Gain, MinPlausibleGain, MaxPlausibleGain)
 	}
 
-	if leadIIISamples != nil {
-		result.Samples.LeadIII = leadIIISamples
+	// Blocks Encode cannot write, such as empty leads, are left out of BlockOrder.
+	// Block ids are 4 bytes, so the joined ids compare the lists.
+	blockOrder := blockWriteOrder(result, state.blockOrder)
+	if strings.Join(blockOrder, "") != strings.Join(defaultBlockOrder(result), "") {
+		result.BlockOrder = blockOrder
 	}
 
-	if aVRSamples != nil {
-		result.Samples.AVR = aVRSamples
+	return result, checksumErrors, readErr
+}
+
+// Convert marshals atcData to JSON string
+func Convert(atcData []byte) (jsonStr string, err error) {
+	output, err := ConvertJSONBytes(atcData)
+	return string(output), err
+}
+
+// ConvertJSONBytes is Convert returning the JSON as bytes, avoiding the copy
+// into a string for callers that write or embed it as bytes
+func ConvertJSONBytes(atcData []byte) ([]byte, error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return nil, err
 	}
 
-	if aVLSamples != nil {
-		result.Samples.AVL = aVLSamples
+	ecgData.summarize()
+	return json.Marshal(&ecgData)
+}
+
+// ConvertWithOptions marshals atcData parsed with opts to JSON string, with the
+// samples in the units selected by opts.UnitMode
+func ConvertWithOptions(atcData []byte, opts ParseOptions) (jsonStr string, err error) {
+	ecgData, _, err := ParseWithOptions(atcData, opts)
+	if err != nil {
+		return "", err
 	}
 
-	if aVFSamples != nil {
-		result.Samples.AVF = aVFSamples
+	output, err := MarshalWithOptions(ecgData, opts)
+	return string(output), err
+}
+
+// MarshalWithOptions returns the JSON ConvertWithOptions writes for ecg, filling in
+// the fields it derives from the samples. Of opts only UnitMode, MillivoltDecimals,
+// Analyze and Indent apply.
+func MarshalWithOptions(ecg *EcgData, opts ParseOptions) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := EncodeWithOptions(buf, ecg, opts)
+	if err != nil {
+		return nil, err
 	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
 
-	result.Info = infoBlock
+// EncodeWithOptions writes the JSON of MarshalWithOptions for ecg to w followed by a
+// newline, without building it in memory as a string. opts.Indent indents it.
+func EncodeWithOptions(w io.Writer, ecg *EcgData, opts ParseOptions) error {
+	var v interface{}
+	switch opts.UnitMode {
+	case UnitRaw:
+		ecg.summarize()
+		if opts.Analyze {
+			ecg.Analyze()
+		}
+		v = ecg
+	case UnitMicrovolts:
+		v = ecg.Microvolts()
+	case UnitMillivolts:
+		v = ecg.MillivoltsWithDecimals(opts.millivoltDecimals())
+	default:
+		return fmt.Errorf("Unsupported unit mode: %d", opts.UnitMode)
+	}
 
-	return result, nil
+	enc := json.NewEncoder(w)
+	if opts.Indent != "" {
+		enc.SetIndent("", opts.Indent)
+	}
+	return enc.Encode(v)
 }
 
-// Convert marshals atcData to JSON string
-func Convert(atcData []byte) (jsonStr string, err error) {
+// ConvertTo writes the JSON of atcData to w without building it in memory as a
+// string. The output is that of Convert followed by a newline.
+func ConvertTo(w io.Writer, atcData []byte) error {
 	ecgData, err := Parse(atcData)
+	if err != nil {
+		return err
+	}
+	return EncodeWithOptions(w, ecgData, ParseOptions{})
+}
+
+// Duration returns the length of the recording, the sample count of the longest
+// lead divided by Frequency, or 0 when Frequency is not positive
+func (ecg *EcgData) Duration() time.Duration {
+	if ecg.Frequency <= 0 {
+		return 0
+	}
+	samples := 0
+	for _, lead := range ecg.Samples.Leads() {
+		if len(lead.Samples) > samples {
+			samples = len(lead.Samples)
+		}
+	}
+	return time.Duration(math.Round(float64(samples) * float64(time.Second) / float64(ecg.Frequency)))
+}
+
+// CalcLeadInfo returns the sample count and duration in seconds of every present lead
+func (ecg *EcgData) CalcLeadInfo() map[string]LeadInfo {
+	leadInfo := map[string]LeadInfo{}
+	for _, lead := range ecg.Samples.Leads() {
+		info := LeadInfo{SampleCount: len(lead.Samples)}
+		if ecg.Frequency > 0 {
+			info.Duration = float32(len(lead.Samples)) / ecg.Frequency
+		}
+		leadInfo[lead.Name] = info
+	}
+	return leadInfo
+}
+
+// ConvertContext is Convert that returns ctx.Err() promptly once ctx is cancelled
+func ConvertContext(ctx context.Context, atcData []byte) (jsonStr string, err error) {
+	ecgData, _, err := ParseReaderContext(ctx, bytes.NewReader(atcData), ParseOptions{})
 	if err != nil {
 		return "", err
 	}
 
+	ecgData.summarize()
 	output, err := json.Marshal(&ecgData)
 	return string(output), err
 }
 
+// summarize fills in the metadata fields emitted by Convert
+func (ecg *EcgData) summarize() {
+	if ecg.Gain > 0 {
+		ecg.GainUnit = GainUnitLSBPerMillivolt
+		ecg.MicrovoltsPerLSB = 1000 / float64(ecg.Gain)
+	}
+	ecg.LeadInfo = ecg.CalcLeadInfo()
+}
+
+// Analyze fills in the fields computed from the samples: DetectedMainsFrequency,
+// DurationSeconds, Stats, HeartRateBpm, Beats, LeadOff and ContentSHA256. Convert
+// leaves them empty, ConvertWithOptions sets them when ParseOptions.Analyze is set.
+// The QRS detector runs once, the heart rate is taken from the detected beats.
+func (ecg *EcgData) Analyze() {
+	ecg.DetectedMainsFrequency = ecg.DetectMainsFrequency()
+	ecg.DurationSeconds = ecg.Duration().Seconds()
+	ecg.Stats = ecg.Samples.statsWithGains(ecg.LeadGain)
+
+	ecg.Beats = ecg.DetectBeats()
+	ecg.HeartRateBpm = 0
+	heartRate, err := heartRateFromBeats(ecg.Beats, len(ecg.rhythmLead()), ecg.Frequency)
+	if err == nil {
+		ecg.HeartRateBpm = heartRate
+	}
+	ecg.LeadOff = ecg.LeadOffIntervals()
+	ecg.ContentSHA256 = ecg.ContentHash()
+}
+
+// ConvertIndent marshals atcData to indented JSON string, see json.MarshalIndent
+func ConvertIndent(atcData []byte, prefix, indent string) (jsonStr string, err error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return "", err
+	}
+
+	ecgData.summarize()
+	output, err := json.MarshalIndent(&ecgData, prefix, indent)
+	return string(output), err
+}
+
 func calcChecksum(data []byte) uint32 {
-	var sum int32
+	sum := newChecksum()
+	sum.Write(data)
+	return sum.Sum32()
+}
 
-	for _, b := range data {
-		sum += int32(b)
+// readBlock decodes the block body into v and discards any trailing bytes
+// so that the whole body is accounted for in the checksum
+func readBlock(blockId string, body io.Reader, v interface{}, order binary.ByteOrder) error {
+	err := binary.Read(body, order, v)
+	if err != nil {
+		return fmt.Errorf("Error reading buffer: %s", err.Error())
+	}
+	_, err = io.Copy(ioutil.Discard, body)
+	if err != nil {
+		return fmt.Errorf("Error reading buffer: %s", err.Error())
 	}
+	return nil
+}
 
-	return uint32(sum)
+// readBytes reads length bytes from body, failing with io.ErrUnexpectedEOF and the
+// bytes read when it ends early. At most maxBlockPrealloc bytes are allocated ahead
+// of the data read, so a corrupt length cannot force a huge allocation.
+func readBytes(body io.Reader, length uint32) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, minInt(int(length), maxBlockPrealloc)))
+	n, err := io.Copy(buf, io.LimitReader(body, int64(length)))
+	if err != nil {
+		return nil, err
+	}
+	if n < int64(length) {
+		return buf.Bytes(), io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
 }
 
-func verifyChecksum(data []byte, blockStart int64, blockLen uint32, reader io.Reader) (err error) {
-	var checksum uint32
-	binary.Read(reader, binary.LittleEndian, &checksum)
+// readBlockChecksum reads the checksum following a block body. A file ending
+// before it is truncated, so io.EOF is reported as io.ErrUnexpectedEOF.
+func readBlockChecksum(r io.Reader, order binary.ByteOrder, checksum *uint32) error {
+	err := binary.Read(r, order, checksum)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
 
-	sum := calcChecksum(data[blockStart : blockStart+8+int64(blockLen)])
+func verifyChecksum(checksum uint32, blockId string, blockStart int64, sum hash.Hash32) (err error) {
+	calculated := sum.Sum32()
 
-	if checksum != sum {
-		return fmt.Errorf("Checksum does not match. Expected: [%v] Calculated:[%v]", checksum, sum)
+	if checksum != calculated {
+		return &ChecksumError{
+			BlockID:    blockId,
+			Offset:     blockStart,
+			Expected:   checksum,
+			Calculated: calculated,
+		}
 	}
 	return nil
 }
 
-func calcMillivolts(data []int16, scale float32) []float32 {
-	result := make([]float32, len(data))
-	for i, sample := range data {
-		result[i] = float32(sample) / scale
+// infoString trims the null padding from an info field. Embedded nulls and
+// invalid UTF-8 sequences are replaced with the Unicode replacement character.
+func infoString(field []byte) string {
+	s := strings.TrimRight(string(field), "\x00")
+	s = strings.Replace(s, "\x00", "\uFFFD", -1)
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
 	}
-	return result
+	return b
 }
@@ -1,8 +1,17 @@
 package atc2json
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestCalcChecksum(t *testing.T) {
@@ -12,9 +21,587 @@ func TestCalcChecksum(t *testing.T) {
 	assert.NotEqual(t, uint(0), res, "0 and %d should be not equal", res)
 }
 
-func TestCalcMillivolts(t *testing.T) {
-	data := []int16{2000, 1000, 0, -1000, -2000}
-	scale := float32(2000)
-	res := calcMillivolts(data, scale)
-	assert.Equal(t, []float32{1, 0.5, 0, -0.5, -1}, res, "Arrays should be equal")
+func TestInfoBlockToJSON(t *testing.T) {
+	info := InfoBlock{}
+	copy(info.DateRecorded[:], "2012-04-03T14:17:43-7:00")
+	copy(info.PhoneModel[:], "iPhone\x00X")
+	copy(info.Location[:], "bad\xffbyte")
+	res := info.ToJSON()
+	assert.Equal(t, "2012-04-03T14:17:43-7:00", res.DateRecorded)
+	assert.Equal(t, "", res.RecordingUUID)
+	assert.Equal(t, "iPhone�X", res.PhoneModel)
+	assert.Equal(t, "bad�byte", res.Location)
+}
+
+func TestConvertInfoStrings(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	jsonStr, err := Convert(atcData)
+	assert.Nil(t, err)
+	// The top-level key keeps its original name
+	assert.Contains(t, jsonStr, `"Info":{"dateRecorded":`)
+	assert.Contains(t, jsonStr, `"recordingUUID":"1285733B-9A84-4349-A845-52FCC436353F"`)
+	assert.Contains(t, jsonStr, `"recorderSoftware":"AliveECG v1.6.9.354"`)
+	assert.Contains(t, jsonStr, `"recordedAt":"2012-04-03T14:17:43-07:00"`)
+}
+
+func TestParseReader(t *testing.T) {
+	f, err := os.Open("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	defer f.Close()
+	ecg, err := ParseReader(f)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(300), ecg.Frequency)
+	assert.Equal(t, 500, ecg.AmplitudeResolution)
+	assert.Len(t, ecg.Samples.LeadI, 9000)
+}
+
+func TestParseReaderSkipsLargeUnknownBlock(t *testing.T) {
+	unknown := make([]byte, 3*contextReadChunk+1)
+	for i := range unknown {
+		unknown[i] = byte(i)
+	}
+	atcData, err := Encode(&EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		UnknownBlocks:       []RawBlock{{ID: "ann ", Data: unknown}},
+	})
+	assert.Nil(t, err)
+	buf := bytes.NewBuffer(atcData)
+	assert.Nil(t, writeBlock(buf, "ecg ", []int16{1, 2, 3}))
+
+	// Reads returning a byte at a time must still skip the whole unknown block
+	ecg, err := ParseReader(iotest.OneByteReader(bytes.NewReader(buf.Bytes())))
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1, 2, 3}, ecg.Samples.LeadI)
+}
+
+func TestParseChecksumMismatch(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	atcData[len(atcData)-10]++
+	_, err = Parse(atcData)
+	checksumErr, ok := err.(*ChecksumError)
+	assert.True(t, ok, "expected *ChecksumError, got %v", err)
+	assert.Equal(t, "ecg ", checksumErr.BlockID)
+	assert.Equal(t, int64(308), checksumErr.Offset)
+	assert.Equal(t, checksumErr.Expected+1, checksumErr.Calculated)
+}
+
+func TestParseWithOptionsSkipChecksumErrors(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Samples: EcgSamples{
+			LeadI:  []int16{1, 2, 3},
+			LeadII: []int16{4, 5, 6},
+		},
+	}
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	// Corrupt the last sample of lead I
+	atcData[len(atcData)-18-ChecksumLength-1]++
+
+	_, _, err = ParseWithOptions(atcData, ParseOptions{})
+	assert.IsType(t, &ChecksumError{}, err)
+
+	res, checksumErrors, err := ParseWithOptions(atcData, ParseOptions{SkipChecksumErrors: true})
+	assert.Nil(t, err)
+	assert.Len(t, checksumErrors, 1)
+	assert.Equal(t, "ecg ", checksumErrors[0].BlockID)
+	assert.Nil(t, res.Samples.LeadI)
+	assert.Equal(t, []int16{4, 5, 6}, res.Samples.LeadII)
+}
+
+func TestConvertIndent(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	jsonStr, err := ConvertIndent(atcData, "", "  ")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(jsonStr, "{\n  \"frequency\": 300,\n"))
+}
+
+func TestCalcLeadInfo(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Samples:   EcgSamples{LeadI: make([]int16, 9000), LeadII: make([]int16, 8997)},
+	}
+	res := ecg.CalcLeadInfo()
+	assert.Equal(t, map[string]LeadInfo{
+		"leadI":  {SampleCount: 9000, Duration: 30},
+		"leadII": {SampleCount: 8997, Duration: 29.99},
+	}, res)
+}
+
+func TestDuration(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Samples:   EcgSamples{LeadI: make([]int16, 8997), LeadII: make([]int16, 9000)},
+	}
+	assert.Equal(t, 30*time.Second, ecg.Duration())
+	ecg.Samples.LeadII = nil
+	assert.Equal(t, 29990*time.Millisecond, ecg.Duration())
+	assert.Equal(t, time.Duration(0), (&EcgData{Samples: ecg.Samples}).Duration())
+}
+
+func TestConvertLeadInfo(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	jsonStr, err := Convert(atcData)
+	assert.Nil(t, err)
+	assert.Contains(t, jsonStr, `"leadInfo":{"leadI":{"sampleCount":9000,"duration":30}}`)
+	assert.Contains(t, jsonStr, `"format":1,`)
+	assert.Contains(t, jsonStr, `"gain":2000,"gainUnit":"LSB/mV","microvoltsPerLsb":0.5,`)
+
+	// The analyses are left out unless asked for
+	for _, key := range []string{"detectedMainsFrequency", "durationSeconds", "stats", "heartRateBpm", "beats", "leadOff", "contentHash"} {
+		assert.NotContains(t, jsonStr, `"`+key+`":`)
+	}
+	jsonStr, err = ConvertWithOptions(atcData, ParseOptions{Analyze: true})
+	assert.Nil(t, err)
+	assert.Contains(t, jsonStr, `"detectedMainsFrequency":`)
+	assert.Contains(t, jsonStr, `"durationSeconds":30,`)
+	assert.Contains(t, jsonStr, `"heartRateBpm":61.7`)
+}
+
+func TestAnalyze(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	ecg.Analyze()
+	assert.Equal(t, 30.0, ecg.DurationSeconds)
+	assert.Equal(t, ecg.DetectBeats(), ecg.Beats)
+	heartRate, err := ecg.EstimateHeartRate()
+	assert.Nil(t, err)
+	assert.Equal(t, heartRate, ecg.HeartRateBpm)
+	assert.Equal(t, ecg.ContentHash(), ecg.ContentSHA256)
+	assert.Contains(t, ecg.Stats, "leadI")
+}
+
+func TestConvertJSONBytes(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	jsonStr, err := Convert(atcData)
+	assert.Nil(t, err)
+	output, err := ConvertJSONBytes(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, jsonStr, string(output))
+
+	output, err = ConvertJSONBytes(atcData[:10])
+	assert.Nil(t, output)
+	assert.NotNil(t, err)
+}
+
+func TestConvertDeterministic(t *testing.T) {
+	samples := make([]int16, 3000)
+	for i := range samples {
+		samples[i] = int16(i % 300)
+	}
+	atcData, err := Encode(&EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		Samples:             EcgSamples{LeadI: samples, LeadII: samples, AVF: samples, V1: samples, V6: samples},
+	})
+	assert.Nil(t, err)
+
+	// Map sections are written with sorted keys, so repeated conversions are identical
+	opts := ParseOptions{Analyze: true}
+	first, err := ConvertWithOptions(atcData, opts)
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		output, err := ConvertWithOptions(atcData, opts)
+		assert.Nil(t, err)
+		assert.Equal(t, first, output)
+	}
+
+	stats := first[strings.Index(first, `"stats":`):]
+	keys := []int{}
+	for _, name := range []string{"aVF", "leadI", "leadII", "v1", "v6"} {
+		keys = append(keys, strings.Index(stats, `"`+name+`":{`))
+	}
+	assert.True(t, sort.IntsAreSorted(keys), "%v", keys)
+}
+
+func TestParseMissingFmtBlock(t *testing.T) {
+	atcData, err := Encode(&EcgData{Frequency: 300, Gain: 2000, Samples: EcgSamples{LeadI: []int16{1}}})
+	assert.Nil(t, err)
+
+	// Drop the fmt block that directly follows the file header
+	fmtEnd := 12 + 8 + 8 + ChecksumLength
+	noFmt := append(append([]byte{}, atcData[:12]...), atcData[fmtEnd:]...)
+	_, err = Parse(noFmt)
+	assert.EqualError(t, err, "Missing fmt block")
+}
+
+func TestParseZeroResolution(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+	writeBlock(buf, "fmt ", &FmtBlock{Format: 1, Frequency: 300})
+
+	_, err := Parse(buf.Bytes())
+	assert.EqualError(t, err, "Invalid fmt block: amplitude resolution is 0")
+}
+
+func TestParseUnsupportedFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+	writeBlock(buf, "fmt ", &FmtBlock{Format: 9, Frequency: 300, Resolution: 500})
+
+	_, err := Parse(buf.Bytes())
+	assert.EqualError(t, err, "Unsupported sample format: unknown(0x09)")
+}
+
+func TestSampleFormatString(t *testing.T) {
+	assert.Equal(t, "raw", SampleFormatRaw.String())
+	assert.Equal(t, "delta", SampleFormatDelta.String())
+	assert.Equal(t, "unknown(0x00)", SampleFormat(0).String())
+	assert.Equal(t, "unknown(0xff)", SampleFormat(255).String())
+
+	// JSON keeps the numeric value
+	output, err := json.Marshal(&EcgData{Format: SampleFormatDelta})
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"format":2,`)
+}
+
+func TestParseTruncatedSamples(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	// Cut the file 1001 bytes into the ecg block body
+	_, err = Parse(atcData[:308+8+1001])
+	assert.Equal(t, &TruncatedBlockError{BlockID: "ecg ", Expected: 9000, Got: 500}, err)
+
+	_, err = Parse(atcData[:308+8])
+	assert.Equal(t, &TruncatedBlockError{BlockID: "ecg ", Expected: 9000, Got: 0}, err)
+
+	// Cut the file after the ecg block body, before or inside its checksum
+	for _, cut := range []int{ChecksumLength, 2} {
+		truncated := atcData[:len(atcData)-cut]
+		_, err = Parse(truncated)
+		assert.EqualError(t, err, "Error reading input: unexpected EOF", "cut %d", cut)
+
+		ecg, checksumErrors, err := ParseWithOptions(truncated, ParseOptions{SkipChecksumErrors: true})
+		assert.Nil(t, ecg)
+		assert.Nil(t, checksumErrors)
+		assert.EqualError(t, err, "Error reading input: unexpected EOF", "cut %d", cut)
+	}
+}
+
+func TestParseReturnPartial(t *testing.T) {
+	atcData, err := Encode(&EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		Samples:             EcgSamples{LeadI: []int16{1, 2}, LeadII: []int16{3, 4}, V6: []int16{5, 6}},
+	})
+	assert.Nil(t, err)
+
+	// Cut the file inside the V6 block
+	truncated := atcData[:len(atcData)-ChecksumLength-2]
+	ecg, _, err := ParseWithOptions(truncated, ParseOptions{})
+	assert.Nil(t, ecg)
+	assert.IsType(t, &TruncatedBlockError{}, err)
+
+	ecg, _, err = ParseWithOptions(truncated, ParseOptions{ReturnPartial: true})
+	assert.Equal(t, &TruncatedBlockError{BlockID: "ec12", Expected: 2, Got: 1}, err)
+	assert.Equal(t, float32(300), ecg.Frequency)
+	assert.Equal(t, []int16{1, 2}, ecg.Samples.LeadI)
+	assert.Equal(t, []int16{3, 4}, ecg.Samples.LeadII)
+	assert.Nil(t, ecg.Samples.V6)
+
+	// A bad checksum stops reading unless it is skipped
+	corrupt := append([]byte{}, atcData...)
+	corrupt[len(corrupt)-ChecksumLength-1]++
+	ecg, _, err = ParseWithOptions(corrupt, ParseOptions{ReturnPartial: true})
+	assert.IsType(t, &ChecksumError{}, err)
+	assert.Equal(t, []int16{3, 4}, ecg.Samples.LeadII)
+	assert.Nil(t, ecg.Samples.V6)
+
+	// Without the fmt block there is nothing to return
+	ecg, _, err = ParseWithOptions(atcData[:20], ParseOptions{ReturnPartial: true})
+	assert.Nil(t, ecg)
+	assert.NotNil(t, err)
+}
+
+func TestParseShortFmtBlock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+	assert.Nil(t, writeBlock(buf, "fmt ", []byte{byte(SampleFormatRaw), 0x2c, 0x01}))
+
+	_, err := Parse(buf.Bytes())
+	assert.EqualError(t, err, "Invalid fmt block: length 3, expected at least 8")
+}
+
+func TestParseEmptyLeadBlock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+	assert.Nil(t, writeBlock(buf, "fmt ", &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 500}))
+	assert.Nil(t, writeBlock(buf, "ecg ", []int16{1, 2}))
+	assert.Nil(t, writeBlock(buf, "ecg2", []int16{}))
+
+	ecg, err := Parse(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1, 2}, ecg.Samples.LeadI)
+	assert.Nil(t, ecg.Samples.LeadII)
+	assert.Len(t, ecg.Samples.Leads(), 1)
+
+	output, err := json.Marshal(ecg.Samples)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"leadI":[1,2]}`, string(output))
+}
+
+func TestParseDuplicateLead(t *testing.T) {
+	build := func(format SampleFormat) []byte {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+		assert.Nil(t, writeBlock(buf, "fmt ", &FmtBlock{Format: byte(format), Frequency: 300, Resolution: 500}))
+		assert.Nil(t, writeBlock(buf, "ecg ", []int16{1, 2}))
+		assert.Nil(t, writeBlock(buf, "ecg2", []int16{7}))
+		assert.Nil(t, writeBlock(buf, "ecg ", []int16{3, 4, 5}))
+		return buf.Bytes()
+	}
+
+	ecg, _, err := ParseWithOptions(build(SampleFormatRaw), ParseOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{3, 4, 5}, ecg.Samples.LeadI)
+	assert.Equal(t, []int16{7}, ecg.Samples.LeadII)
+
+	ecg, _, err = ParseWithOptions(build(SampleFormatRaw), ParseOptions{OnDuplicateLead: DuplicateLeadAppend})
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1, 2, 3, 4, 5}, ecg.Samples.LeadI)
+
+	// Each delta block starts from zero
+	ecg, _, err = ParseWithOptions(build(SampleFormatDelta), ParseOptions{OnDuplicateLead: DuplicateLeadAppend})
+	assert.Nil(t, err)
+	assert.Equal(t, []int16{1, 3, 3, 7, 12}, ecg.Samples.LeadI)
+
+	_, _, err = ParseWithOptions(build(SampleFormatRaw), ParseOptions{OnDuplicateLead: DuplicateLeadError})
+	assert.EqualError(t, err, `Duplicate "ecg " block at offset 62`)
+}
+
+func TestEcgSamplesLead(t *testing.T) {
+	s := &EcgSamples{LeadII: []int16{1}, V3: []int16{2}}
+	assert.Equal(t, []int16{1}, s.Lead("leadII"))
+	assert.Equal(t, []int16{1}, s.Lead("II"))
+	assert.Equal(t, []int16{2}, s.Lead("V3"))
+	assert.Nil(t, s.Lead("leadI"))
+	assert.Nil(t, s.Lead("V7"))
+
+	ecg := &EcgData{Samples: *s}
+	samples, ok := ecg.Lead("II")
+	assert.True(t, ok)
+	assert.Equal(t, []int16{1}, samples)
+	_, ok = ecg.Lead("leadI")
+	assert.False(t, ok)
+}
+
+func TestParseOverrides(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	gain, frequency := float32(1000), float32(250)
+	ecg, _, err := ParseWithOptions(atcData, ParseOptions{GainOverride: &gain, FrequencyOverride: &frequency})
+	assert.Nil(t, err)
+	assert.Equal(t, float32(1000), ecg.Gain)
+	assert.Equal(t, 1000, ecg.AmplitudeResolution)
+	assert.Equal(t, float32(250), ecg.Frequency)
+
+	output, err := json.Marshal(ecg)
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"frequency":250,"amplitudeResolution":1000,"mainsFrequency":60,"gain":1000`)
+
+	// An override makes a zero resolution usable
+	zeroResolution := append([]byte{}, atcData...)
+	binary.LittleEndian.PutUint16(zeroResolution[288+8+3:], 0)
+	binary.LittleEndian.PutUint32(zeroResolution[304:], calcChecksum(zeroResolution[288:304]))
+	_, err = Parse(zeroResolution)
+	assert.NotNil(t, err)
+	ecg, _, err = ParseWithOptions(zeroResolution, ParseOptions{GainOverride: &gain})
+	assert.Nil(t, err)
+	assert.Equal(t, float32(1000), ecg.Gain)
+
+	zero := float32(0)
+	_, _, err = ParseWithOptions(atcData, ParseOptions{GainOverride: &zero})
+	assert.NotNil(t, err)
+}
+
+func TestParseFlags(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x0e, ecg.Flags)
+	assert.True(t, ecg.IsMains60Hz())
+	assert.Equal(t, 60, ecg.MainsFrequency)
+
+	jsonStr, err := Convert(atcData)
+	assert.Nil(t, err)
+	assert.Contains(t, jsonStr, `"format":1,"flags":14,`)
+}
+
+func TestConvertTo(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	expected, err := Convert(atcData)
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	err = ConvertTo(buf, atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, expected+"\n", buf.String())
+
+	err = ConvertTo(buf, atcData[:10])
+	assert.NotNil(t, err)
+}
+
+func TestParseByteOrder(t *testing.T) {
+	// Build a big-endian file by hand
+	buf := &bytes.Buffer{}
+	buf.Write(AtcFileSignature[:])
+	binary.Write(buf, binary.BigEndian, uint32(2))
+	for _, block := range []struct {
+		id string
+		v  interface{}
+	}{
+		{"fmt ", &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 500, Flags: FmtFlagMains60Hz}},
+		{"ecg ", []int16{1, -2, 300}},
+	} {
+		body := &bytes.Buffer{}
+		binary.Write(body, binary.BigEndian, block.v)
+		blockBuf := &bytes.Buffer{}
+		blockBuf.WriteString(block.id)
+		binary.Write(blockBuf, binary.BigEndian, uint32(body.Len()))
+		blockBuf.Write(body.Bytes())
+		buf.Write(blockBuf.Bytes())
+		binary.Write(buf, binary.BigEndian, calcChecksum(blockBuf.Bytes()))
+	}
+	binary.Write(buf, binary.BigEndian, calcChecksum(buf.Bytes()))
+
+	opts := ParseOptions{ByteOrder: binary.BigEndian}
+	ecg, _, err := ParseWithOptions(buf.Bytes(), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(300), ecg.Frequency)
+	assert.Equal(t, float32(2000), ecg.Gain)
+	assert.Equal(t, 60, ecg.MainsFrequency)
+	assert.Equal(t, []int16{1, -2, 300}, ecg.Samples.LeadI)
+	assert.True(t, ecg.FileChecksumVerified)
+
+	_, err = Parse(buf.Bytes())
+	assert.NotNil(t, err)
+
+	// The functions that only walk the blocks take the byte order too
+	assert.Nil(t, ValidateWithOptions(buf.Bytes(), opts))
+	assert.NotNil(t, Validate(buf.Bytes()))
+
+	locations, err := IndexWithOptions(buf.Bytes(), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []BlockLocation{
+		{ID: "fmt ", Offset: 12, Length: 8, ChecksumOK: true},
+		{ID: "ecg ", Offset: 32, Length: 6, ChecksumOK: true},
+		{ID: FileChecksumBlockID, Offset: 50, ChecksumOK: true},
+	}, locations)
+
+	var ids []string
+	err = BlocksWithOptions(buf.Bytes(), opts, func(id string, body []byte) error {
+		ids = append(ids, id)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fmt ", "ecg "}, ids)
+
+	leads, err := LeadsPresentWithOptions(buf.Bytes(), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"leadI"}, leads)
+
+	info, fmtBlock, err := ParseInfoWithOptions(buf.Bytes(), opts)
+	assert.Nil(t, err)
+	assert.Nil(t, info)
+	assert.Equal(t, &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 500, Flags: FmtFlagMains60Hz}, fmtBlock)
+}
+
+func TestParseIncludeRawBlocks(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	ecg, _, err := ParseWithOptions(atcData, ParseOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, ecg.RawBlocks)
+
+	ecg, _, err = ParseWithOptions(atcData, ParseOptions{IncludeRawBlocks: true})
+	assert.Nil(t, err)
+	assert.Len(t, ecg.RawBlocks, 3)
+	for i, location := range []BlockLocation{{ID: "info", Offset: 12, Length: 264}, {ID: "fmt ", Offset: 288, Length: 8}, {ID: "ecg ", Offset: 308, Length: 18000}} {
+		block := ecg.RawBlocks[i]
+		assert.Equal(t, location.ID, block.ID)
+		assert.Equal(t, location.Offset, block.Offset)
+		bodyStart := location.Offset + 8
+		bodyEnd := bodyStart + int64(location.Length)
+		assert.Equal(t, atcData[bodyStart:bodyEnd], block.Data)
+		assert.Equal(t, binary.LittleEndian.Uint32(atcData[bodyEnd:]), block.Checksum)
+		assert.Equal(t, calcChecksum(atcData[location.Offset:bodyEnd]), block.Checksum)
+	}
+
+	// Unknown blocks are included even when they are not kept
+	encoded, err := Encode(&EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		Samples:             EcgSamples{LeadI: []int16{1}},
+		UnknownBlocks:       []RawBlock{{ID: "ann ", Data: []byte{1, 2, 3}}},
+	})
+	assert.Nil(t, err)
+	ecg, _, err = ParseWithOptions(encoded, ParseOptions{IncludeRawBlocks: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "ann ", ecg.RawBlocks[2].ID)
+	assert.Equal(t, []byte{1, 2, 3}, ecg.RawBlocks[2].Data)
+	assert.Nil(t, ecg.UnknownBlocks)
+}
+
+func TestParseSignatureSubtype(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, "", ecg.SignatureSubtype)
+
+	marked := append([]byte{}, atcData...)
+	copy(marked[5:8], "\x03\x01\x00")
+	ecg, err = Parse(marked)
+	assert.Nil(t, err)
+	assert.Equal(t, "030100", ecg.SignatureSubtype)
+	assert.Nil(t, Validate(marked))
+
+	ecg.FileVersion = EncodeFileVersion
+	encoded, err := Encode(ecg)
+	assert.Nil(t, err)
+	assert.Equal(t, marked[:8], encoded[:8])
+
+	ecg.SignatureSubtype = "03"
+	_, err = Encode(ecg)
+	assert.EqualError(t, err, `Invalid signature subtype "03"`)
+
+	copy(marked, "ALIVX")
+	_, err = Parse(marked)
+	assert.EqualError(t, err, "Wrong file signature")
+}
+
+func TestParseMaxSupportedVersion(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	ecg, _, err := ParseWithOptions(atcData, ParseOptions{MaxSupportedVersion: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, ecg.FileVersion)
+
+	_, _, err = ParseWithOptions(atcData, ParseOptions{MaxSupportedVersion: 1})
+	assert.Equal(t, &UnsupportedVersionError{Version: 2, MaxSupported: 1}, err)
+	assert.EqualError(t, err, "Unsupported file version 2, newest supported is 1")
 }
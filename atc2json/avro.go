@@ -0,0 +1,83 @@
+package atc2json
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+const avroMagic = "Obj\x01"
+
+// AvroSchema is the schema of the records written by WriteAvro, one per recording.
+// Absent leads are null.
+const AvroSchema = `{"type":"record","name":"EcgRecording","namespace":"com.alivecor.atc2json","fields":[` +
+	`{"name":"frequency","type":"float"},` +
+	`{"name":"gain","type":"float"},` +
+	`{"name":"mainsFrequency","type":"int"},` +
+	`{"name":"leadI","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"leadII","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"leadIII","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"aVR","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"aVL","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"aVF","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"v1","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"v2","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"v3","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"v4","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"v5","type":["null",{"type":"array","items":"int"}],"default":null},` +
+	`{"name":"v6","type":["null",{"type":"array","items":"int"}],"default":null}]}`
+
+// WriteAvro writes ecg to w as an uncompressed Avro object container file holding
+// a single AvroSchema record, with the schema embedded in the file header.
+func WriteAvro(w io.Writer, ecg *EcgData) error {
+	record := &bytes.Buffer{}
+	binary.Write(record, binary.LittleEndian, math.Float32bits(ecg.Frequency))
+	binary.Write(record, binary.LittleEndian, math.Float32bits(ecg.Gain))
+	writeUvarint(record, zigzag(int64(ecg.MainsFrequency)))
+	for _, ref := range ecg.Samples.leadRefs() {
+		if *ref == nil {
+			writeUvarint(record, zigzag(0))
+			continue
+		}
+		writeUvarint(record, zigzag(1))
+		if len(*ref) > 0 {
+			writeUvarint(record, zigzag(int64(len(*ref))))
+			for _, s := range *ref {
+				writeUvarint(record, zigzag(int64(s)))
+			}
+		}
+		writeUvarint(record, zigzag(0))
+	}
+
+	// The sync marker only has to be unlikely to occur in the data, deriving it
+	// from the record keeps the output deterministic
+	digest := sha256.Sum256(record.Bytes())
+	sync := digest[:16]
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(avroMagic)
+	metadata := [][2]string{{"avro.codec", "null"}, {"avro.schema", AvroSchema}}
+	writeUvarint(buf, zigzag(int64(len(metadata))))
+	for _, kv := range metadata {
+		writeAvroBytes(buf, []byte(kv[0]))
+		writeAvroBytes(buf, []byte(kv[1]))
+	}
+	writeUvarint(buf, zigzag(0))
+	buf.Write(sync)
+
+	writeUvarint(buf, zigzag(1))
+	writeUvarint(buf, zigzag(int64(record.Len())))
+	buf.Write(record.Bytes())
+	buf.Write(sync)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeAvroBytes writes b as Avro bytes or string, a length followed by the data
+func writeAvroBytes(buf *bytes.Buffer, b []byte) {
+	writeUvarint(buf, zigzag(int64(len(b))))
+	buf.Write(b)
+}
@@ -0,0 +1,79 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func readAvroLong(t *testing.T, r *bytes.Reader) int64 {
+	v, err := binary.ReadUvarint(r)
+	assert.Nil(t, err)
+	return unzigzag(v)
+}
+
+func readAvroBytes(t *testing.T, r *bytes.Reader) []byte {
+	b := make([]byte, readAvroLong(t, r))
+	_, err := io.ReadFull(r, b)
+	assert.Nil(t, err)
+	return b
+}
+
+func TestWriteAvro(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteAvro(buf, ecg))
+	assert.True(t, json.Valid([]byte(AvroSchema)))
+
+	r := bytes.NewReader(buf.Bytes())
+	magic := make([]byte, 4)
+	r.Read(magic)
+	assert.Equal(t, avroMagic, string(magic))
+
+	metadata := map[string]string{}
+	for n := readAvroLong(t, r); n > 0; n = readAvroLong(t, r) {
+		for i := int64(0); i < n; i++ {
+			key := string(readAvroBytes(t, r))
+			metadata[key] = string(readAvroBytes(t, r))
+		}
+	}
+	assert.Equal(t, map[string]string{"avro.codec": "null", "avro.schema": AvroSchema}, metadata)
+	sync := make([]byte, 16)
+	r.Read(sync)
+
+	assert.Equal(t, int64(1), readAvroLong(t, r))
+	size := readAvroLong(t, r)
+	assert.Equal(t, int64(r.Len()-16), size)
+
+	var bits [2]uint32
+	binary.Read(r, binary.LittleEndian, &bits)
+	assert.Equal(t, ecg.Frequency, math.Float32frombits(bits[0]))
+	assert.Equal(t, ecg.Gain, math.Float32frombits(bits[1]))
+	assert.Equal(t, int64(ecg.MainsFrequency), readAvroLong(t, r))
+
+	var decoded EcgSamples
+	for _, ref := range decoded.leadRefs() {
+		if readAvroLong(t, r) == 0 {
+			continue
+		}
+		*ref = []int16{}
+		for n := readAvroLong(t, r); n > 0; n = readAvroLong(t, r) {
+			for i := int64(0); i < n; i++ {
+				*ref = append(*ref, int16(readAvroLong(t, r)))
+			}
+		}
+	}
+	assert.Equal(t, ecg.Samples, decoded)
+
+	rest, _ := ioutil.ReadAll(r)
+	assert.Equal(t, sync, rest)
+}
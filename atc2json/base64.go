@@ -0,0 +1,48 @@
+package atc2json
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Base64SampleEncoding marks samples encoded as base64 little-endian int16 bytes
+const Base64SampleEncoding = "base64-int16-le"
+
+// EcgBase64Data is EcgData with every present lead as a base64 string of its
+// little-endian int16 samples, keyed by lead name
+type EcgBase64Data struct {
+	*EcgData
+	Encoding string            `json:"encoding"`
+	Samples  map[string]string `json:"samples"`
+}
+
+// Base64 returns ecg with base64 encoded samples
+func (ecg *EcgData) Base64() *EcgBase64Data {
+	result := &EcgBase64Data{
+		EcgData:  ecg,
+		Encoding: Base64SampleEncoding,
+		Samples:  map[string]string{},
+	}
+
+	for _, lead := range ecg.Samples.Leads() {
+		buf := make([]byte, len(lead.Samples)*2)
+		for i, s := range lead.Samples {
+			binary.LittleEndian.PutUint16(buf[2*i:], uint16(s))
+		}
+		result.Samples[lead.Name] = base64.StdEncoding.EncodeToString(buf)
+	}
+	return result
+}
+
+// ConvertBase64 is Convert with every lead encoded as a base64 string
+func ConvertBase64(atcData []byte) (jsonStr string, err error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return "", err
+	}
+
+	ecgData.summarize()
+	output, err := json.Marshal(ecgData.Base64())
+	return string(output), err
+}
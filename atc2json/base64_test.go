@@ -0,0 +1,45 @@
+package atc2json
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestEcgDataBase64(t *testing.T) {
+	ecg := &EcgData{Frequency: 300, Gain: 2000, Samples: EcgSamples{LeadI: []int16{1, -1, 256}}}
+
+	output, err := json.Marshal(ecg.Base64())
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"frequency":300`)
+	assert.Contains(t, string(output), `"encoding":"base64-int16-le"`)
+	assert.Contains(t, string(output), `"samples":{"leadI":"AQD//wAB"}`)
+}
+
+func TestConvertBase64(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	jsonStr, err := Convert(atcData)
+	assert.Nil(t, err)
+	base64Str, err := ConvertBase64(atcData)
+	assert.Nil(t, err)
+	assert.True(t, len(base64Str) < len(jsonStr)*2/3)
+
+	var normal, encoded map[string]interface{}
+	json.Unmarshal([]byte(jsonStr), &normal)
+	json.Unmarshal([]byte(base64Str), &encoded)
+
+	// Everything but the samples and the encoding marker matches Convert
+	samples := encoded["samples"].(map[string]interface{})
+	delete(normal, "samples")
+	delete(encoded, "samples")
+	delete(encoded, "encoding")
+	assert.Equal(t, normal, encoded)
+
+	data, err := base64.StdEncoding.DecodeString(samples["leadI"].(string))
+	assert.Nil(t, err)
+	assert.Equal(t, 18000, len(data))
+}
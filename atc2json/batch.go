@@ -0,0 +1,86 @@
+package atc2json
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ConvertResult is the outcome of converting a single file in ConvertDir
+type ConvertResult struct {
+	// Path is the input .atc file
+	Path string
+	// OutPath is the .json file written on success
+	OutPath string
+	Err     error
+}
+
+// ConvertDir converts every *.atc file in inDir to a .json file of the same name in
+// outDir using up to workers concurrent conversions. A failing file is reported in
+// its result and does not stop the batch. Results are in input path order.
+func ConvertDir(inDir, outDir string, workers int) ([]ConvertResult, error) {
+	paths, err := filepath.Glob(filepath.Join(inDir, "*.atc"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(outDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]ConvertResult, len(paths))
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = convertFile(paths[i], outDir)
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// convertFile converts path to JSON in outDir
+func convertFile(path string, outDir string) ConvertResult {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".json"
+	result := ConvertResult{Path: path}
+
+	atcData, err := ioutil.ReadFile(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	jsonStr, err := Convert(atcData)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	outPath := filepath.Join(outDir, name)
+	err = ioutil.WriteFile(outPath, []byte(jsonStr), 0644)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.OutPath = outPath
+	return result
+}
@@ -0,0 +1,41 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertDir(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	inDir, err := ioutil.TempDir("", "batch-in")
+	assert.Nil(t, err)
+	defer os.RemoveAll(inDir)
+	outDir, err := ioutil.TempDir("", "batch-out")
+	assert.Nil(t, err)
+	defer os.RemoveAll(outDir)
+
+	ioutil.WriteFile(filepath.Join(inDir, "a.atc"), atcData, 0644)
+	ioutil.WriteFile(filepath.Join(inDir, "b.atc"), atcData[:100], 0644)
+	ioutil.WriteFile(filepath.Join(inDir, "c.atc"), atcData, 0644)
+	ioutil.WriteFile(filepath.Join(inDir, "notes.txt"), []byte("skip"), 0644)
+
+	results, err := ConvertDir(inDir, filepath.Join(outDir, "json"), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+	assert.Equal(t, "", results[1].OutPath)
+	assert.Nil(t, results[2].Err)
+	assert.Equal(t, filepath.Join(outDir, "json", "c.json"), results[2].OutPath)
+
+	expected, _ := Convert(atcData)
+	output, err := ioutil.ReadFile(results[0].OutPath)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(output))
+}
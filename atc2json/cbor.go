@@ -0,0 +1,176 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// CBOR major types, RFC 8949 section 3.1
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+)
+
+// ConvertCBOR is Convert with CBOR (RFC 8949) output. Maps use the JSON field names
+// and omitempty rules, samples are encoded as CBOR integers and byte slices,
+// base64 strings in JSON, as byte strings.
+func ConvertCBOR(atcData []byte) ([]byte, error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return nil, err
+	}
+
+	ecgData.summarize()
+	buf := &bytes.Buffer{}
+	err = encodeCBOR(buf, reflect.ValueOf(ecgData))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeCBOR writes v to buf, following the encoding/json mapping of Go values
+func encodeCBOR(buf *bytes.Buffer, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | 22)
+			return nil
+		}
+		if v.Kind() == reflect.Ptr && !v.Type().Implements(jsonMarshalerType) {
+			return encodeCBOR(buf, v.Elem())
+		}
+	}
+
+	// Types with custom JSON encodings are encoded from their decoded JSON
+	if v.Type().Implements(jsonMarshalerType) {
+		decoded, err := decodeMarshaler(v)
+		if err != nil {
+			return err
+		}
+		return encodeCBOR(buf, decoded)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return encodeCBOR(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeCBORInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeCBORHead(buf, cborUnsigned, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(cborSimple<<5 | 26)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		buf.WriteByte(cborSimple<<5 | 27)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				writeCBORInt(buf, i)
+				return nil
+			}
+			f, err := n.Float64()
+			if err != nil {
+				return err
+			}
+			return encodeCBOR(buf, reflect.ValueOf(f))
+		}
+		writeCBORHead(buf, cborText, uint64(v.Len()))
+		buf.WriteString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | 22)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			writeCBORHead(buf, cborBytes, uint64(v.Len()))
+			buf.Write(v.Bytes())
+			return nil
+		}
+		writeCBORHead(buf, cborArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			err := encodeCBOR(buf, v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(cborSimple<<5 | 22)
+			return nil
+		}
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		writeCBORHead(buf, cborMap, uint64(len(keys)))
+		for _, key := range keys {
+			writeCBORHead(buf, cborText, uint64(len(key)))
+			buf.WriteString(key)
+			err := encodeCBOR(buf, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		names, values := jsonFields(v)
+		writeCBORHead(buf, cborMap, uint64(len(names)))
+		for i, name := range names {
+			writeCBORHead(buf, cborText, uint64(len(name)))
+			buf.WriteString(name)
+			err := encodeCBOR(buf, values[i])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported CBOR type: %s", v.Type())
+	}
+	return nil
+}
+
+func writeCBORInt(buf *bytes.Buffer, i int64) {
+	if i < 0 {
+		writeCBORHead(buf, cborNegative, uint64(-1-i))
+		return
+	}
+	writeCBORHead(buf, cborUnsigned, uint64(i))
+}
+
+// writeCBORHead writes the initial byte of a data item of the given major type and
+// its argument n, in the shortest form
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
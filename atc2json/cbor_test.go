@@ -0,0 +1,135 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// decodeCBOR decodes the subset of CBOR written by encodeCBOR, returning the rest of data
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("Unexpected end of CBOR")
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, fmt.Errorf("Unexpected end of CBOR")
+		}
+		buf := make([]byte, 8)
+		copy(buf[8-size:], data[:size])
+		n = binary.BigEndian.Uint64(buf)
+		data = data[size:]
+	default:
+		return nil, nil, fmt.Errorf("Unsupported CBOR argument %d", info)
+	}
+
+	switch major {
+	case cborUnsigned:
+		return int64(n), data, nil
+	case cborNegative:
+		return -1 - int64(n), data, nil
+	case cborBytes:
+		return append([]byte{}, data[:n]...), data[n:], nil
+	case cborText:
+		return string(data[:n]), data[n:], nil
+	case cborArray:
+		items := make([]interface{}, n)
+		for i := range items {
+			var err error
+			items[i], data, err = decodeCBOR(data)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, data, nil
+	case cborMap:
+		items := map[string]interface{}{}
+		for i := uint64(0); i < n; i++ {
+			key, rest, err := decodeCBOR(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key.(string)], data, err = decodeCBOR(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, data, nil
+	case cborSimple:
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		case 26:
+			return math.Float32frombits(uint32(n)), data, nil
+		case 27:
+			return math.Float64frombits(n), data, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("Unsupported CBOR major type %d", major)
+}
+
+func TestConvertCBOR(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	output, err := ConvertCBOR(atcData)
+	assert.Nil(t, err)
+	decoded, rest, err := decodeCBOR(output)
+	assert.Nil(t, err)
+	assert.Empty(t, rest)
+
+	fields := decoded.(map[string]interface{})
+	assert.Equal(t, float32(300), fields["frequency"])
+	assert.Equal(t, int64(60), fields["mainsFrequency"])
+	assert.Equal(t, "1285733B-9A84-4349-A845-52FCC436353F", fields["Info"].(map[string]interface{})["recordingUUID"])
+
+	leadI := fields["samples"].(map[string]interface{})["leadI"].([]interface{})
+	assert.Len(t, leadI, len(ecg.Samples.LeadI))
+	for i, sample := range leadI {
+		if sample != int64(ecg.Samples.LeadI[i]) {
+			t.Fatalf("Sample %d is %v, expected %d", i, sample, ecg.Samples.LeadI[i])
+		}
+	}
+}
+
+func TestEncodeCBOR(t *testing.T) {
+	type sample struct {
+		Name    string  `json:"name"`
+		Skipped int     `json:"skipped,omitempty"`
+		Values  []int16 `json:"values"`
+		Data    []byte  `json:"data"`
+		OK      bool
+		Ratio   float32 `json:"ratio"`
+	}
+
+	buf := &bytes.Buffer{}
+	err := encodeCBOR(buf, reflect.ValueOf(&sample{Name: "a", Values: []int16{1, -1, -25, 200, -300}, Data: []byte{7}, OK: true, Ratio: 1}))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0xa5,
+		0x64, 'n', 'a', 'm', 'e', 0x61, 'a',
+		0x66, 'v', 'a', 'l', 'u', 'e', 's', 0x85, 0x01, 0x20, 0x38, 0x18, 0x18, 0xc8, 0x39, 0x01, 0x2b,
+		0x64, 'd', 'a', 't', 'a', 0x41, 0x07,
+		0x62, 'O', 'K', 0xf5,
+		0x65, 'r', 'a', 't', 'i', 'o', 0xfa, 0x3f, 0x80, 0x00, 0x00,
+	}, buf.Bytes())
+}
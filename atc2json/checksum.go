@@ -0,0 +1,116 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// checksum is a hash.Hash32 computing the ATC block checksum, which is
+// the running sum of every byte in the block header and body
+type checksum struct {
+	sum int32
+}
+
+var _ hash.Hash32 = (*checksum)(nil)
+
+func newChecksum() *checksum {
+	return &checksum{}
+}
+
+func (c *checksum) Write(p []byte) (int, error) {
+	n := len(p)
+	var sum uint32
+
+	// Sum eight bytes at a time in four 16-bit lanes. A lane grows by at most
+	// 2*255 per word, so the lanes are folded into sum every 128 words.
+	for len(p) >= 8 {
+		words := len(p) / 8
+		if words > 128 {
+			words = 128
+		}
+
+		var lanes uint64
+		for i := 0; i < words; i++ {
+			x := binary.LittleEndian.Uint64(p[8*i:])
+			lanes += x&0x00ff00ff00ff00ff + (x>>8)&0x00ff00ff00ff00ff
+		}
+		sum += uint32(lanes&0xffff + (lanes>>16)&0xffff + (lanes>>32)&0xffff + lanes>>48)
+		p = p[8*words:]
+	}
+
+	for _, b := range p {
+		sum += uint32(b)
+	}
+
+	c.sum += int32(sum)
+	return n, nil
+}
+
+// add adds the bytes summed by other to c
+func (c *checksum) add(other *checksum) {
+	c.sum += other.sum
+}
+
+func (c *checksum) Sum(b []byte) []byte {
+	s := c.Sum32()
+	return append(b, byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+func (c *checksum) Sum32() uint32 {
+	return uint32(c.sum)
+}
+
+func (c *checksum) Reset() {
+	c.sum = 0
+}
+
+func (c *checksum) Size() int {
+	return ChecksumLength
+}
+
+func (c *checksum) BlockSize() int {
+	return 1
+}
+
+// ChecksumError is returned when the checksum stored after a block does not
+// match the checksum calculated over the block header and body
+type ChecksumError struct {
+	BlockID    string
+	Offset     int64
+	Expected   uint32
+	Calculated uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("Checksum does not match for block %q at offset %d. Expected: [%v] Calculated:[%v]",
+		e.BlockID, e.Offset, e.Expected, e.Calculated)
+}
+
+// verifyFileChecksum compares the trailing whole-file checksum against the
+// sum of every byte before it
+func verifyFileChecksum(trailer []byte, order binary.ByteOrder, offset int64, calculated uint32) error {
+	expected := order.Uint32(trailer)
+	if expected != calculated {
+		return &ChecksumError{
+			BlockID:    FileChecksumBlockID,
+			Offset:     offset,
+			Expected:   expected,
+			Calculated: calculated,
+		}
+	}
+	return nil
+}
+
+// countingReader tracks the number of bytes read so block offsets can be reported
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
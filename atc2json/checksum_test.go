@@ -0,0 +1,93 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestChecksumRunningSum(t *testing.T) {
+	sum := newChecksum()
+	sum.Write([]byte{'A', 2})
+	sum.Write([]byte{3, 'z'})
+	assert.Equal(t, uint32(192), sum.Sum32())
+	assert.Equal(t, []byte{0, 0, 0, 192}, sum.Sum(nil))
+
+	sum.Reset()
+	assert.Equal(t, uint32(0), sum.Sum32())
+}
+
+func TestParseFileChecksum(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	res, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.False(t, res.FileChecksumVerified)
+
+	trailer := make([]byte, ChecksumLength)
+	binary.LittleEndian.PutUint32(trailer, calcChecksum(atcData))
+	withTrailer := append(append([]byte{}, atcData...), trailer...)
+
+	res, err = Parse(withTrailer)
+	assert.Nil(t, err)
+	assert.True(t, res.FileChecksumVerified)
+	assert.Len(t, res.Samples.LeadI, 9000)
+	assert.Nil(t, Validate(withTrailer))
+
+	withTrailer[len(withTrailer)-1]++
+	_, err = Parse(withTrailer)
+	assert.IsType(t, &ChecksumError{}, err)
+	assert.Equal(t, FileChecksumBlockID, err.(*ChecksumError).BlockID)
+	assert.Equal(t, int64(len(atcData)), err.(*ChecksumError).Offset)
+	assert.NotNil(t, Validate(withTrailer))
+
+	res, checksumErrors, err := ParseWithOptions(withTrailer, ParseOptions{SkipChecksumErrors: true})
+	assert.Nil(t, err)
+	assert.Len(t, checksumErrors, 1)
+	assert.False(t, res.FileChecksumVerified)
+}
+
+func TestRunningChecksumMatchesCalcChecksum(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	locations, err := Index(atcData)
+	assert.Nil(t, err)
+
+	// Parse sums each block while reading it; the result must match a separate pass over the block
+	for _, loc := range locations {
+		block := atcData[loc.Offset : loc.Offset+8+int64(loc.Length)]
+		sum := newChecksum()
+		for _, b := range block {
+			sum.Write([]byte{b})
+		}
+		assert.Equal(t, calcChecksum(block), sum.Sum32(), loc.ID)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(atcData)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Parse(atcData)
+	}
+}
+
+func TestChecksumWriteLanes(t *testing.T) {
+	data := make([]byte, 8*300+5)
+	var expected uint32
+	for i := range data {
+		data[i] = byte(255 - i%7)
+		expected += uint32(data[i])
+	}
+
+	sum := newChecksum()
+	sum.Write(data)
+	assert.Equal(t, expected, sum.Sum32())
+}
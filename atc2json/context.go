@@ -0,0 +1,27 @@
+package atc2json
+
+import (
+	"context"
+	"io"
+)
+
+// contextReadChunk bounds each read so that large sample blocks are read in
+// several chunks with the context checked in between
+const contextReadChunk = 64 * 1024
+
+// contextReader fails reads with ctx.Err() once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	err := c.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	if len(p) > contextReadChunk {
+		p = p[:contextReadChunk]
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,51 @@
+package atc2json
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+// cancelReader cancels its context after a number of reads
+type cancelReader struct {
+	r      *bytes.Reader
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	c.reads--
+	if c.reads == 0 {
+		c.cancel()
+	}
+	return c.r.Read(p)
+}
+
+func TestConvertContext(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	expected, err := Convert(atcData)
+	assert.Nil(t, err)
+	res, err := ConvertContext(context.Background(), atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, res)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ConvertContext(ctx, atcData)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParseReaderContextCancelledWithinSamples(t *testing.T) {
+	ecg := &EcgData{Frequency: 300, Gain: 2000, Samples: EcgSamples{LeadI: make([]int16, 3*contextReadChunk)}}
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &cancelReader{r: bytes.NewReader(atcData), cancel: cancel, reads: 3}
+	_, _, err = ParseReaderContext(ctx, r, ParseOptions{})
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,116 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVOptions controls the columns written by ConvertCSVWithOptions
+type CSVOptions struct {
+	// IncludeTime adds a leading "time" column in seconds from the start of the recording
+	IncludeTime bool
+	// IncludeTimestamp adds a "timestamp" column with the RFC 3339 time of each sample,
+	// see EcgData.SampleTime. Conversion fails if the recording has no start time.
+	IncludeTimestamp bool
+}
+
+// ConvertCSV converts atcData to CSV with one row per sample and one column per present lead
+func ConvertCSV(atcData []byte) (string, error) {
+	return ConvertCSVWithOptions(atcData, CSVOptions{})
+}
+
+// csvFlushRows is the number of rows WriteCSVWithOptions buffers before flushing
+const csvFlushRows = 1000
+
+// ConvertCSVWithOptions converts atcData to CSV using opts
+func ConvertCSVWithOptions(atcData []byte, opts CSVOptions) (string, error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	err = WriteCSVWithOptions(buf, ecgData, opts)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// WriteCSV writes ecg to w as the CSV of ConvertCSV
+func WriteCSV(w io.Writer, ecg *EcgData) error {
+	return WriteCSVWithOptions(w, ecg, CSVOptions{})
+}
+
+// WriteCSVWithOptions writes ecg to w as CSV using opts. Rows are flushed to w as
+// they are written, so the whole CSV is never held in memory.
+func WriteCSVWithOptions(w io.Writer, ecg *EcgData, opts CSVOptions) error {
+	leads := ecg.Samples.Leads()
+
+	var header []string
+	if opts.IncludeTime {
+		header = append(header, "time")
+	}
+	// The start time is resolved once, each row adds the offset of its sample
+	var start time.Time
+	if opts.IncludeTimestamp {
+		var ok bool
+		start, ok = ecg.SampleTime(0)
+		if !ok {
+			return fmt.Errorf("Recording has no start time")
+		}
+		header = append(header, "timestamp")
+	}
+
+	// Leads may differ in length, stop at the shortest one
+	rows := -1
+	for _, lead := range leads {
+		header = append(header, lead.Name)
+		if rows < 0 || len(lead.Samples) < rows {
+			rows = len(lead.Samples)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(header))
+	for i := 0; i < rows; i++ {
+		col := 0
+		if opts.IncludeTime {
+			record[col] = strconv.FormatFloat(float64(i)/float64(ecg.Frequency), 'f', -1, 64)
+			col++
+		}
+		if opts.IncludeTimestamp {
+			record[col] = start.Add(sampleOffset(i, ecg.Frequency)).Format(time.RFC3339Nano)
+			col++
+		}
+		for _, lead := range leads {
+			record[col] = strconv.Itoa(int(lead.Samples[i]))
+			col++
+		}
+
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+		if (i+1)%csvFlushRows == 0 {
+			writer.Flush()
+			err = writer.Error()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,73 @@
+package atc2json
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConvertCSV(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	csvStr, err := ConvertCSV(atcData)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(csvStr), "\n")
+	assert.Equal(t, "leadI", lines[0])
+	assert.Len(t, lines, 9001)
+
+	csvStr, err = ConvertCSVWithOptions(atcData, CSVOptions{IncludeTime: true})
+	assert.Nil(t, err)
+	lines = strings.Split(strings.TrimSpace(csvStr), "\n")
+	assert.Equal(t, "time,leadI", lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "0.0033333333333333335,"))
+
+	csvStr, err = ConvertCSVWithOptions(atcData, CSVOptions{IncludeTime: true, IncludeTimestamp: true})
+	assert.Nil(t, err)
+	lines = strings.Split(strings.TrimSpace(csvStr), "\n")
+	assert.Equal(t, "time,timestamp,leadI", lines[0])
+	assert.True(t, strings.HasPrefix(lines[301], "1,2012-04-03T14:17:44-07:00,"), lines[301])
+}
+
+// countingWriter records the writes made to it
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	fail   bool
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, fmt.Errorf("disk full")
+	}
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestWriteCSV(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+	expected, err := ConvertCSV(atcData)
+	assert.Nil(t, err)
+
+	// Rows reach w as they are written instead of in a single write at the end
+	w := &countingWriter{}
+	err = WriteCSV(w, ecg)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, w.String())
+	assert.True(t, w.writes >= 9000/csvFlushRows, "%d writes", w.writes)
+
+	err = WriteCSV(&countingWriter{fail: true}, ecg)
+	assert.EqualError(t, err, "disk full")
+}
+
+func TestEcgSamplesLeads(t *testing.T) {
+	samples := EcgSamples{LeadI: []int16{1}, AVF: []int16{2, 3}}
+	leads := samples.Leads()
+	assert.Equal(t, []Lead{{"leadI", []int16{1}}, {"aVF", []int16{2, 3}}}, leads)
+}
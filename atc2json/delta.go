@@ -0,0 +1,22 @@
+package atc2json
+
+// decodeDelta replaces first differences with absolute samples in place.
+// Sums wrap around like the int16 arithmetic used to encode them.
+func decodeDelta(samples []int16) {
+	var last int16
+	for i, delta := range samples {
+		last += delta
+		samples[i] = last
+	}
+}
+
+// encodeDelta returns the first differences of samples, see decodeDelta
+func encodeDelta(samples []int16) []int16 {
+	deltas := make([]int16, len(samples))
+	var last int16
+	for i, sample := range samples {
+		deltas[i] = sample - last
+		last = sample
+	}
+	return deltas
+}
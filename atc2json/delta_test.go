@@ -0,0 +1,29 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecodeDelta(t *testing.T) {
+	samples := []int16{5, -3, 32767, 2}
+	decodeDelta(samples)
+	assert.Equal(t, []int16{5, 2, -32767, -32765}, samples)
+	assert.Equal(t, []int16{5, -3, 32767, 2}, encodeDelta(samples))
+}
+
+func TestParseDeltaFixture(t *testing.T) {
+	rawData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	deltaData, err := ioutil.ReadFile("../fixtures/delta-v2.atc")
+	assert.Nil(t, err)
+
+	raw, err := Parse(rawData)
+	assert.Nil(t, err)
+	delta, err := Parse(deltaData)
+	assert.Nil(t, err)
+
+	assert.Equal(t, SampleFormatDelta, delta.Format)
+	assert.Equal(t, raw.Samples, delta.Samples)
+}
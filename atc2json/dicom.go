@@ -0,0 +1,239 @@
+package atc2json
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+const (
+	dicomECGWaveformStorage  = "1.2.840.10008.5.1.4.1.1.9.1.2"
+	dicomExplicitVRLittle    = "1.2.840.10008.1.2.1"
+	dicomImplementationUID   = "2.25.151336403862233542925862381539614127957"
+	dicomMaxWaveformSamples  = 16384
+	dicomMaxWaveformChannels = 13
+	dicomDateLayout          = "20060102"
+	dicomTimeLayout          = "150405"
+	dicomDateTimeLayout      = "20060102150405-0700"
+	dicomItemGroup           = 0xfffe
+	dicomItemElement         = 0xe000
+	dicomPreambleLength      = 128
+	dicomFileMetaGroup       = 0x0002
+	dicomWaveformBits        = 16
+)
+
+// dicomLongLengthVRs are the VRs encoded with a 4 byte length after 2 reserved bytes
+var dicomLongLengthVRs = map[string]bool{"OB": true, "OW": true, "OF": true, "SQ": true, "UT": true, "UN": true}
+
+// WriteDICOM writes ecg to w as a DICOM Part 10 file holding a General ECG Waveform
+// object in explicit VR little endian. All present leads form a single multiplex
+// group, padded with zero samples to the length of the longest lead.
+func WriteDICOM(w io.Writer, ecg *EcgData) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+	if len(leads) > dicomMaxWaveformChannels {
+		return fmt.Errorf("Too many leads for a General ECG waveform: %d", len(leads))
+	}
+	if ecg.Gain <= 0 || ecg.Frequency <= 0 {
+		return fmt.Errorf("Invalid gain %v or frequency %v", ecg.Gain, ecg.Frequency)
+	}
+
+	length := 0
+	for _, lead := range leads {
+		if len(lead.Samples) > length {
+			length = len(lead.Samples)
+		}
+	}
+	if length > dicomMaxWaveformSamples {
+		return fmt.Errorf("Too many samples for a General ECG waveform: %d", length)
+	}
+
+	var info InfoBlockJSON
+	var seed string
+	if ecg.Info != nil {
+		info = ecg.Info.ToJSON()
+		seed = ecg.Info.recordingID()
+	}
+
+	// UIDs are derived from the recording UUID so that repeated exports agree,
+	// whatever the spelling of the UUID
+	if seed == "" {
+		random := make([]byte, 16)
+		_, err := rand.Read(random)
+		if err != nil {
+			return err
+		}
+		seed = string(random)
+	}
+	instanceUID := dicomUID(seed + "/instance")
+	studyUID := dicomUID(seed + "/study")
+	seriesUID := dicomUID(seed + "/series")
+
+	meta := &bytes.Buffer{}
+	writeDICOMElement(meta, 0x0002, 0x0001, "OB", []byte{0, 1})
+	writeDICOMString(meta, 0x0002, 0x0002, "UI", dicomECGWaveformStorage)
+	writeDICOMString(meta, 0x0002, 0x0003, "UI", instanceUID)
+	writeDICOMString(meta, 0x0002, 0x0010, "UI", dicomExplicitVRLittle)
+	writeDICOMString(meta, 0x0002, 0x0012, "UI", dicomImplementationUID)
+
+	var date, tm, dateTime string
+	if ecg.Info != nil {
+		if t, err := ecg.Info.RecordedAt(); err == nil {
+			date = t.Format(dicomDateLayout)
+			tm = t.Format(dicomTimeLayout)
+			dateTime = t.Format(dicomDateTimeLayout)
+		}
+	}
+
+	data := &bytes.Buffer{}
+	writeDICOMString(data, 0x0008, 0x0016, "UI", dicomECGWaveformStorage)
+	writeDICOMString(data, 0x0008, 0x0018, "UI", instanceUID)
+	writeDICOMString(data, 0x0008, 0x0020, "DA", date)
+	writeDICOMString(data, 0x0008, 0x0023, "DA", date)
+	writeDICOMString(data, 0x0008, 0x002a, "DT", dateTime)
+	writeDICOMString(data, 0x0008, 0x0030, "TM", tm)
+	writeDICOMString(data, 0x0008, 0x0033, "TM", tm)
+	writeDICOMString(data, 0x0008, 0x0050, "SH", "")
+	writeDICOMString(data, 0x0008, 0x0060, "CS", "ECG")
+	writeDICOMString(data, 0x0008, 0x0070, "LO", "AliveCor")
+	writeDICOMString(data, 0x0008, 0x0090, "PN", "")
+	writeDICOMString(data, 0x0008, 0x1090, "LO", info.PhoneModel)
+	writeDICOMString(data, 0x0010, 0x0010, "PN", "")
+	writeDICOMString(data, 0x0010, 0x0020, "LO", "")
+	writeDICOMString(data, 0x0010, 0x0030, "DA", "")
+	writeDICOMString(data, 0x0010, 0x0040, "CS", "")
+	writeDICOMString(data, 0x0018, 0x1000, "LO", info.PhoneUDID)
+	writeDICOMString(data, 0x0018, 0x1020, "LO", info.RecorderSoftware)
+	writeDICOMString(data, 0x0020, 0x000d, "UI", studyUID)
+	writeDICOMString(data, 0x0020, 0x000e, "UI", seriesUID)
+	writeDICOMString(data, 0x0020, 0x0010, "SH", "")
+	writeDICOMString(data, 0x0020, 0x0011, "IS", "1")
+	writeDICOMString(data, 0x0020, 0x0013, "IS", "1")
+
+	units := &bytes.Buffer{}
+	writeDICOMCode(units, "uV", "UCUM", "microvolt")
+
+	channels := &bytes.Buffer{}
+	for _, lead := range leads {
+		source := &bytes.Buffer{}
+		writeDICOMCode(source, fmt.Sprintf("5.6.3-9-%d", scpLeadIDs[lead.Name]), "SCPECG", "Lead "+shortLeadName(lead.Name))
+
+		// Channel sensitivity is in microvolts per sample unit
+		sensitivity := strconv.FormatFloat(1000/float64(ecg.LeadGain(lead.Name)), 'g', 10, 64)
+		channel := &bytes.Buffer{}
+		writeDICOMSequence(channel, 0x003a, 0x0208, source.Bytes())
+		writeDICOMString(channel, 0x003a, 0x0210, "DS", sensitivity)
+		writeDICOMSequence(channel, 0x003a, 0x0211, units.Bytes())
+		writeDICOMString(channel, 0x003a, 0x0212, "DS", "1")
+		writeDICOMString(channel, 0x003a, 0x0213, "DS", "0")
+		writeDICOMString(channel, 0x003a, 0x0214, "DS", "0")
+		writeDICOMUint16(channel, 0x003a, 0x021a, dicomWaveformBits)
+		writeDICOMItem(channels, channel.Bytes())
+	}
+
+	samples := make([]int16, length*len(leads))
+	for j, lead := range leads {
+		for i, s := range lead.Samples {
+			samples[i*len(leads)+j] = s
+		}
+	}
+	waveformData := &bytes.Buffer{}
+	binary.Write(waveformData, binary.LittleEndian, samples)
+
+	waveform := &bytes.Buffer{}
+	writeDICOMString(waveform, 0x003a, 0x0004, "CS", "ORIGINAL")
+	writeDICOMUint16(waveform, 0x003a, 0x0005, uint16(len(leads)))
+	numSamples := make([]byte, 4)
+	binary.LittleEndian.PutUint32(numSamples, uint32(length))
+	writeDICOMElement(waveform, 0x003a, 0x0010, "UL", numSamples)
+	writeDICOMString(waveform, 0x003a, 0x001a, "DS", strconv.FormatFloat(float64(ecg.Frequency), 'g', 10, 64))
+	writeDICOMElement(waveform, 0x003a, 0x0200, "SQ", channels.Bytes())
+	writeDICOMUint16(waveform, 0x5400, 0x1004, dicomWaveformBits)
+	writeDICOMString(waveform, 0x5400, 0x1006, "CS", "SS")
+	writeDICOMElement(waveform, 0x5400, 0x1010, "OW", waveformData.Bytes())
+	writeDICOMSequence(data, 0x5400, 0x0100, waveform.Bytes())
+
+	header := &bytes.Buffer{}
+	header.Write(make([]byte, dicomPreambleLength))
+	header.WriteString("DICM")
+	groupLength := make([]byte, 4)
+	binary.LittleEndian.PutUint32(groupLength, uint32(meta.Len()))
+	writeDICOMElement(header, dicomFileMetaGroup, 0x0000, "UL", groupLength)
+
+	for _, b := range [][]byte{header.Bytes(), meta.Bytes(), data.Bytes()} {
+		_, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDICOMElement writes an explicit VR little endian data element
+func writeDICOMElement(buf *bytes.Buffer, group, element uint16, vr string, value []byte) {
+	binary.Write(buf, binary.LittleEndian, group)
+	binary.Write(buf, binary.LittleEndian, element)
+	buf.WriteString(vr)
+	if dicomLongLengthVRs[vr] {
+		buf.Write([]byte{0, 0})
+		binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+	} else {
+		binary.Write(buf, binary.LittleEndian, uint16(len(value)))
+	}
+	buf.Write(value)
+}
+
+// writeDICOMString writes a string element padded to an even length, with a NUL for
+// UIDs and a space for every other VR
+func writeDICOMString(buf *bytes.Buffer, group, element uint16, vr string, value string) {
+	b := []byte(value)
+	if len(b)%2 != 0 {
+		if vr == "UI" {
+			b = append(b, 0)
+		} else {
+			b = append(b, ' ')
+		}
+	}
+	writeDICOMElement(buf, group, element, vr, b)
+}
+
+func writeDICOMUint16(buf *bytes.Buffer, group, element uint16, value uint16) {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	writeDICOMElement(buf, group, element, "US", b)
+}
+
+// writeDICOMItem writes a sequence item of explicit length
+func writeDICOMItem(buf *bytes.Buffer, item []byte) {
+	binary.Write(buf, binary.LittleEndian, uint16(dicomItemGroup))
+	binary.Write(buf, binary.LittleEndian, uint16(dicomItemElement))
+	binary.Write(buf, binary.LittleEndian, uint32(len(item)))
+	buf.Write(item)
+}
+
+// writeDICOMSequence writes a sequence element holding a single item
+func writeDICOMSequence(buf *bytes.Buffer, group, element uint16, item []byte) {
+	items := &bytes.Buffer{}
+	writeDICOMItem(items, item)
+	writeDICOMElement(buf, group, element, "SQ", items.Bytes())
+}
+
+// writeDICOMCode writes the code value, scheme and meaning of a code sequence item
+func writeDICOMCode(buf *bytes.Buffer, value, scheme, meaning string) {
+	writeDICOMString(buf, 0x0008, 0x0100, "SH", value)
+	writeDICOMString(buf, 0x0008, 0x0102, "SH", scheme)
+	writeDICOMString(buf, 0x0008, 0x0104, "LO", meaning)
+}
+
+// dicomUID derives a UID under the 2.25 arc from a hash of seed
+func dicomUID(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return "2.25." + new(big.Int).SetBytes(sum[:16]).String()
+}
@@ -0,0 +1,147 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// dicomElements walks the top level explicit VR little endian elements of data
+func dicomElements(t *testing.T, data []byte) map[uint32][]byte {
+	elements := map[uint32][]byte{}
+	last := uint32(0)
+	for len(data) > 0 {
+		tag := uint32(binary.LittleEndian.Uint16(data))<<16 | uint32(binary.LittleEndian.Uint16(data[2:]))
+		assert.True(t, tag > last, "tags in ascending order")
+		last = tag
+
+		vr := string(data[4:6])
+		var length int
+		if dicomLongLengthVRs[vr] {
+			length = int(binary.LittleEndian.Uint32(data[8:]))
+			data = data[12:]
+		} else {
+			length = int(binary.LittleEndian.Uint16(data[6:]))
+			data = data[8:]
+		}
+		assert.Equal(t, 0, length%2)
+		elements[tag] = data[:length]
+		data = data[length:]
+	}
+	return elements
+}
+
+// dicomItems splits the value of a sequence element into its item values
+func dicomItems(t *testing.T, data []byte) [][]byte {
+	var items [][]byte
+	for len(data) > 0 {
+		assert.Equal(t, []byte{0xfe, 0xff, 0x00, 0xe0}, data[:4])
+		length := int(binary.LittleEndian.Uint32(data[4:]))
+		items = append(items, data[8:8+length])
+		data = data[8+length:]
+	}
+	return items
+}
+
+// dicomChannels returns the elements of every channel definition item of the
+// waveform sequence of a WriteDICOM output
+func dicomChannels(t *testing.T, out []byte) []map[uint32][]byte {
+	metaLength := int(binary.LittleEndian.Uint32(out[140:]))
+	data := dicomElements(t, out[144+metaLength:])
+	waveform := dicomElements(t, dicomItems(t, data[0x54000100])[0])
+	var channels []map[uint32][]byte
+	for _, item := range dicomItems(t, waveform[0x003a0200]) {
+		channels = append(channels, dicomElements(t, item))
+	}
+	return channels
+}
+
+func TestWriteDICOM(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "2012-04-03T14:17:43-7:00")
+	copy(info.RecordingUUID[:], "1285733B-9A84-4349-A845-52FCC436353F")
+
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Info:      info,
+		Samples: EcgSamples{
+			LeadI:  []int16{1, 2, 3},
+			LeadII: []int16{-1, -2},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteDICOM(buf, ecg)
+	assert.Nil(t, err)
+
+	out := buf.Bytes()
+	assert.Equal(t, make([]byte, 128), out[:128])
+	assert.Equal(t, "DICM", string(out[128:132]))
+
+	metaLength := int(binary.LittleEndian.Uint32(out[140:]))
+	meta := dicomElements(t, out[144:144+metaLength])
+	assert.Equal(t, "1.2.840.10008.1.2.1\x00", string(meta[0x00020010]))
+
+	data := dicomElements(t, out[144+metaLength:])
+	assert.Equal(t, "20120403", string(data[0x00080020]))
+	assert.Equal(t, "ECG ", string(data[0x00080060]))
+	assert.Equal(t, meta[0x00020003], data[0x00080018])
+
+	// The waveform sequence holds a single item
+	sequence := data[0x54000100]
+	assert.Equal(t, []byte{0xfe, 0xff, 0x00, 0xe0}, sequence[:4])
+	waveform := dicomElements(t, sequence[8:])
+	assert.Equal(t, len(sequence)-8, int(binary.LittleEndian.Uint32(sequence[4:])))
+	assert.Equal(t, []byte{2, 0}, waveform[0x003a0005])
+	assert.Equal(t, []byte{3, 0, 0, 0}, waveform[0x003a0010])
+	assert.Equal(t, "300 ", string(waveform[0x003a001a]))
+
+	samples := make([]int16, 6)
+	binary.Read(bytes.NewReader(waveform[0x54001010]), binary.LittleEndian, samples)
+	assert.Equal(t, []int16{1, -1, 2, -2, 3, 0}, samples)
+
+	// Every channel carries its correction factor, baseline and time skew
+	for _, channel := range dicomChannels(t, out) {
+		assert.Equal(t, "1 ", string(channel[0x003a0212]))
+		assert.Equal(t, "0 ", string(channel[0x003a0213]))
+		assert.Equal(t, "0 ", string(channel[0x003a0214]))
+	}
+
+	// UIDs are stable for a recording
+	again := &bytes.Buffer{}
+	WriteDICOM(again, ecg)
+	assert.Equal(t, out, again.Bytes())
+
+	// and do not depend on how the UUID is spelled
+	respelled := *info
+	respelled.RecordingUUID = [len(info.RecordingUUID)]byte{}
+	copy(respelled.RecordingUUID[:], "{1285733b9a844349a84552fcc436353f}")
+	ecg.Info = &respelled
+	again.Reset()
+	WriteDICOM(again, ecg)
+	assert.Equal(t, out, again.Bytes())
+}
+
+func TestWriteDICOMTooLong(t *testing.T) {
+	ecg := &EcgData{Frequency: 300, Gain: 2000, Samples: EcgSamples{LeadI: make([]int16, dicomMaxWaveformSamples+1)}}
+	err := WriteDICOM(&bytes.Buffer{}, ecg)
+	assert.NotNil(t, err)
+}
+
+func TestWriteDICOMLeadGains(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		LeadGains: map[string]float32{"leadII": 1000},
+		Samples:   EcgSamples{LeadI: []int16{1}, LeadII: []int16{2}},
+	}
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteDICOM(buf, ecg))
+
+	channels := dicomChannels(t, buf.Bytes())
+	assert.Len(t, channels, 2)
+	assert.Equal(t, "0.5 ", string(channels[0][0x003a0210]))
+	assert.Equal(t, "1 ", string(channels[1][0x003a0210]))
+}
@@ -0,0 +1,221 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	edfHeaderLength       = 256
+	edfSignalHeaderLength = 256
+	edfDigitalMin         = math.MinInt16
+	edfDigitalMax         = math.MaxInt16
+
+	// edfAnnotationsLabel is the label of the EDF+ signal holding the annotations
+	// and the start time of every data record
+	edfAnnotationsLabel = "EDF Annotations"
+)
+
+// EDFOptions controls the layout of the EDF file written by WriteEDFWithOptions
+type EDFOptions struct {
+	// RecordDuration is the length of each data record in seconds, defaults to 1.
+	// Frequency * RecordDuration must be a whole number of samples.
+	RecordDuration float64
+}
+
+// WriteEDF writes ecg to w as an EDF+ file with one second data records
+func WriteEDF(w io.Writer, ecg *EcgData) error {
+	return WriteEDFWithOptions(w, ecg, EDFOptions{})
+}
+
+// WriteEDFWithOptions writes ecg to w as a continuous EDF+ file, one signal per
+// present lead followed by the EDF Annotations signal that EDF+ requires. The
+// final data record is padded with zero samples. The start date and time come
+// from Info.RecordedAt when it parses, in the recording's own time zone.
+func WriteEDFWithOptions(w io.Writer, ecg *EcgData, opts EDFOptions) error {
+	duration := opts.RecordDuration
+	if duration <= 0 {
+		duration = 1
+	}
+
+	samplesPerRecord := float64(ecg.Frequency) * duration
+	recordLen := int(math.Round(samplesPerRecord))
+	if recordLen < 1 || math.Abs(samplesPerRecord-float64(recordLen)) > 1e-6 {
+		return fmt.Errorf("Record duration %v does not hold a whole number of samples at %v Hz", duration, ecg.Frequency)
+	}
+
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+
+	maxLen := 0
+	for _, lead := range leads {
+		if len(lead.Samples) > maxLen {
+			maxLen = len(lead.Samples)
+		}
+	}
+	numRecords := (maxLen + recordLen - 1) / recordLen
+
+	if ecg.Gain <= 0 {
+		return fmt.Errorf("Invalid gain: %v", ecg.Gain)
+	}
+	physicalMin := float64(edfDigitalMin) / float64(ecg.Gain)
+	physicalMax := float64(edfDigitalMax) / float64(ecg.Gain)
+
+	// Unknown dates are written as EDF+ prescribes, 01.01.85 and an X subfield
+	startDate, startTime, recordingDate := "01.01.85", "00.00.00", "X"
+	if ecg.Info != nil {
+		if t, err := ecg.Info.RecordedAt(); err == nil {
+			startDate, startTime = edfDate(t), t.Format("15.04.05")
+			recordingDate = strings.ToUpper(t.Format("02-Jan-2006"))
+		}
+	}
+	equipment := "X"
+	if ecg.Info != nil {
+		if uuid := edfSubfield(ecg.Info.ToJSON().RecordingUUID); uuid != "" {
+			equipment = uuid
+		}
+	}
+
+	// Every data record starts with a time-keeping annotation, the last one is the longest
+	annotationLen := (len(edfRecordOnset(numRecords-1, duration)) + 1) / 2
+
+	signals := len(leads) + 1
+	header := &bytes.Buffer{}
+	writeEDFField(header, "0", 8)
+	writeEDFField(header, "X X X X", 80)
+	writeEDFField(header, "Startdate "+recordingDate+" X X "+equipment, 80)
+	writeEDFField(header, startDate, 8)
+	writeEDFField(header, startTime, 8)
+	writeEDFField(header, strconv.Itoa(edfHeaderLength+signals*edfSignalHeaderLength), 8)
+	writeEDFField(header, "EDF+C", 44)
+	writeEDFField(header, strconv.Itoa(numRecords), 8)
+	writeEDFField(header, edfNumber(duration), 8)
+	writeEDFField(header, strconv.Itoa(signals), 4)
+
+	// Signal headers are stored field by field across all signals
+	for _, lead := range leads {
+		writeEDFField(header, "ECG "+shortLeadName(lead.Name), 16)
+	}
+	writeEDFField(header, edfAnnotationsLabel, 16)
+	for i := 0; i < signals; i++ {
+		writeEDFField(header, "", 80)
+	}
+	for range leads {
+		writeEDFField(header, "mV", 8)
+	}
+	writeEDFField(header, "", 8)
+	for range leads {
+		writeEDFField(header, edfNumber(physicalMin), 8)
+	}
+	writeEDFField(header, "-1", 8)
+	for range leads {
+		writeEDFField(header, edfNumber(physicalMax), 8)
+	}
+	writeEDFField(header, "1", 8)
+	for i := 0; i < signals; i++ {
+		writeEDFField(header, strconv.Itoa(edfDigitalMin), 8)
+	}
+	for i := 0; i < signals; i++ {
+		writeEDFField(header, strconv.Itoa(edfDigitalMax), 8)
+	}
+	for i := 0; i < signals; i++ {
+		writeEDFField(header, "", 80)
+	}
+	for range leads {
+		writeEDFField(header, strconv.Itoa(recordLen), 8)
+	}
+	writeEDFField(header, strconv.Itoa(annotationLen), 8)
+	for i := 0; i < signals; i++ {
+		writeEDFField(header, "", 32)
+	}
+
+	_, err := w.Write(header.Bytes())
+	if err != nil {
+		return err
+	}
+
+	record := make([]int16, recordLen)
+	for r := 0; r < numRecords; r++ {
+		start := r * recordLen
+		for _, lead := range leads {
+			n := 0
+			if start < len(lead.Samples) {
+				n = copy(record, lead.Samples[start:])
+			}
+			for i := n; i < recordLen; i++ {
+				record[i] = 0
+			}
+
+			err = binary.Write(w, binary.LittleEndian, record)
+			if err != nil {
+				return err
+			}
+		}
+
+		annotations := make([]byte, 2*annotationLen)
+		copy(annotations, edfRecordOnset(r, duration))
+		_, err = w.Write(annotations)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// edfRecordOnset returns the time-keeping annotation of data record r, its start
+// in seconds from the start of the file followed by an empty annotation list
+func edfRecordOnset(r int, duration float64) string {
+	onset := math.Round(float64(r)*duration*1e7) / 1e7
+	return "+" + strconv.FormatFloat(onset, 'f', -1, 64) + "\x14\x14\x00"
+}
+
+// edfDate formats the date of t as the dd.mm.yy EDF start date. Two digits only
+// cover 1985 to 2084, EDF+ writes "yy" for other years and relies on the full
+// date in the recording identification.
+func edfDate(t time.Time) string {
+	if t.Year() < 1985 || t.Year() > 2084 {
+		return t.Format("02.01.") + "yy"
+	}
+	return t.Format("02.01.06")
+}
+
+// edfSubfield makes s usable as a subfield of the EDF+ patient or recording
+// identification, which separates subfields with spaces
+func edfSubfield(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' {
+			return '_'
+		}
+		if r < 32 || r > 126 {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+// writeEDFField writes value as a space padded ASCII field of the given width
+func writeEDFField(buf *bytes.Buffer, value string, width int) {
+	if len(value) > width {
+		value = value[:width]
+	}
+	buf.WriteString(value)
+	buf.WriteString(strings.Repeat(" ", width-len(value)))
+}
+
+// edfNumber formats v with as much precision as fits in an 8 character EDF field
+func edfNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	for prec := 6; len(s) > 8 && prec >= 0; prec-- {
+		s = strconv.FormatFloat(v, 'f', prec, 64)
+	}
+	return s
+}
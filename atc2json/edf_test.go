@@ -0,0 +1,87 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWriteEDF(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 4,
+		Gain:      2000,
+		Samples: EcgSamples{
+			LeadI:  []int16{1, 2, 3, 4, 5, 6},
+			LeadII: []int16{-1, -2, -3, -4, -5, -6},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteEDF(buf, ecg)
+	assert.Nil(t, err)
+
+	// Two leads and the annotations signal, whose 3 samples hold "+0\x14\x14\x00"
+	out := buf.Bytes()
+	recordLen := 2*4*2 + 3*2
+	assert.Equal(t, 256+3*256+2*recordLen, len(out))
+	assert.Equal(t, "1024", strings.TrimSpace(string(out[184:192])))
+	assert.Equal(t, "EDF+C", strings.TrimSpace(string(out[192:236])))
+	assert.Equal(t, "2", strings.TrimSpace(string(out[236:244])))
+	assert.Equal(t, "3", strings.TrimSpace(string(out[252:256])))
+	assert.Equal(t, "Startdate X X X X", strings.TrimSpace(string(out[88:168])))
+	assert.Equal(t, "01.01.85", string(out[168:176]))
+	assert.Equal(t, "ECG I", strings.TrimSpace(string(out[256:272])))
+	assert.Equal(t, edfAnnotationsLabel, strings.TrimSpace(string(out[256+2*16:256+3*16])))
+	assert.Equal(t, "-16.384", strings.TrimSpace(string(out[256+3*104:256+3*104+8])))
+	assert.Equal(t, "3", strings.TrimSpace(string(out[256+3*216+2*8:256+3*216+3*8])))
+
+	data := make([]int16, 8)
+	binary.Read(bytes.NewReader(out[1024:]), binary.LittleEndian, data)
+	assert.Equal(t, []int16{1, 2, 3, 4, -1, -2, -3, -4}, data)
+	assert.Equal(t, "+0\x14\x14\x00\x00", string(out[1024+16:1024+recordLen]))
+
+	binary.Read(bytes.NewReader(out[1024+recordLen:]), binary.LittleEndian, data)
+	assert.Equal(t, []int16{5, 6, 0, 0, -5, -6, 0, 0}, data)
+	assert.Equal(t, "+1\x14\x14\x00\x00", string(out[1024+recordLen+16:]))
+}
+
+func TestWriteEDFStartDate(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "2012-04-03T14:17:43-7:00")
+	copy(info.RecordingUUID[:], "1285733B-9A84-4349-A845-52FCC436353F")
+	ecg := &EcgData{Frequency: 300, Gain: 2000, Info: info, Samples: EcgSamples{LeadI: make([]int16, 10)}}
+
+	buf := &bytes.Buffer{}
+	err := WriteEDF(buf, ecg)
+	assert.Nil(t, err)
+	out := buf.Bytes()
+	assert.Equal(t, "Startdate 03-APR-2012 X X 1285733B-9A84-4349-A845-52FCC436353F", strings.TrimSpace(string(out[88:168])))
+	assert.Equal(t, "03.04.12", string(out[168:176]))
+	assert.Equal(t, "14.17.43", string(out[176:184]))
+
+	ecg.Gain = 0
+	err = WriteEDF(&bytes.Buffer{}, ecg)
+	assert.EqualError(t, err, "Invalid gain: 0")
+}
+
+func TestWriteEDFRecordDuration(t *testing.T) {
+	ecg := &EcgData{Frequency: 300, Gain: 2000, Samples: EcgSamples{LeadI: make([]int16, 10)}}
+
+	err := WriteEDFWithOptions(&bytes.Buffer{}, ecg, EDFOptions{RecordDuration: 0.001})
+	assert.NotNil(t, err)
+
+	buf := &bytes.Buffer{}
+	err = WriteEDFWithOptions(buf, ecg, EDFOptions{RecordDuration: 0.3})
+	assert.Nil(t, err)
+	assert.Equal(t, "0.3", strings.TrimSpace(string(buf.Bytes()[244:252])))
+
+	buf = &bytes.Buffer{}
+	err = WriteEDFWithOptions(buf, ecg, EDFOptions{RecordDuration: 0.01})
+	assert.Nil(t, err)
+	assert.Equal(t, "4", strings.TrimSpace(string(buf.Bytes()[236:244])))
+	// Record onsets are written without floating point noise
+	assert.Contains(t, buf.String(), "+0.03\x14\x14\x00")
+	assert.NotContains(t, buf.String(), "00000001")
+}
@@ -0,0 +1,219 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// EncodeFileVersion is the ATC file version written by Encode when the
+// FileVersion of the EcgData being encoded is 0
+const EncodeFileVersion = 2
+
+// Encode builds an ATC file from ecg. Blocks are written in the order of
+// BlockOrder, followed by the blocks it does not list in the default order:
+// info, fmt, one ecg block per present lead, then any UnknownBlocks. FmtExtra is
+// written at the end of the fmt block. When FileChecksumVerified is set the file
+// ends with a whole-file checksum.
+func Encode(ecg *EcgData) ([]byte, error) {
+	fmtBlock, err := encodeFmtBlock(ecg)
+	if err != nil {
+		return nil, err
+	}
+
+	version := ecg.FileVersion
+	if version == 0 {
+		version = EncodeFileVersion
+	}
+	if version < 0 || version > math.MaxUint32 {
+		return nil, fmt.Errorf("Invalid file version: %d", ecg.FileVersion)
+	}
+	for _, block := range ecg.UnknownBlocks {
+		if len(block.ID) != 4 {
+			return nil, fmt.Errorf("Invalid block id %q", block.ID)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+
+	header := AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: uint32(version)}
+	if ecg.SignatureSubtype != "" {
+		subtype, err := hex.DecodeString(ecg.SignatureSubtype)
+		if err != nil || len(subtype) != len(AtcFileSignature)-len(AtcSignaturePrefix) {
+			return nil, fmt.Errorf("Invalid signature subtype %q", ecg.SignatureSubtype)
+		}
+		copy(header.FileSignature[len(AtcSignaturePrefix):], subtype)
+	}
+	binary.Write(buf, binary.LittleEndian, &header)
+
+	leads := map[string]*[]int16{}
+	for i, ref := range ecg.Samples.leadRefs() {
+		leads[leadDefinitions[i].BlockID] = ref
+	}
+	unknownWritten := map[string]int{}
+	for _, id := range blockWriteOrder(ecg, ecg.BlockOrder) {
+		switch {
+		case id == "info":
+			err = writeBlock(buf, id, ecg.Info)
+		case id == "fmt ":
+			// Space after word is intended, per spec - cp 2019-2-19
+			fmtBody := &bytes.Buffer{}
+			binary.Write(fmtBody, binary.LittleEndian, fmtBlock)
+			fmtBody.Write(ecg.FmtExtra)
+			err = writeBlock(buf, id, fmtBody.Bytes())
+		case leads[id] != nil:
+			samples := *leads[id]
+			if SampleFormat(fmtBlock.Format) == SampleFormatDelta {
+				samples = encodeDelta(samples)
+			}
+			err = writeBlock(buf, id, samples)
+		default:
+			// Unknown blocks with the same id keep their relative order
+			err = writeBlock(buf, id, nthUnknownBlock(ecg.UnknownBlocks, id, unknownWritten[id]).Data)
+			unknownWritten[id]++
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ecg.FileChecksumVerified {
+		binary.Write(buf, binary.LittleEndian, calcChecksum(buf.Bytes()))
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJSON builds an ATC file from the JSON written by Convert, see Encode.
+// Fields derived from the samples, such as leadInfo and beats, are ignored.
+func EncodeJSON(jsonData []byte) ([]byte, error) {
+	ecg := &EcgData{}
+	err := json.Unmarshal(jsonData, ecg)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading JSON: %s", err.Error())
+	}
+	return Encode(ecg)
+}
+
+// encodeFmtBlock reconstructs the fmt block from the parsed fields of ecg
+func encodeFmtBlock(ecg *EcgData) (*FmtBlock, error) {
+	resolution := ecg.AmplitudeResolution
+	if ecg.Gain != 0 {
+		resolution = int(math.Round(1e6 / float64(ecg.Gain)))
+	}
+	if resolution <= 0 || resolution > math.MaxUint16 {
+		return nil, fmt.Errorf("Invalid amplitude resolution: %d", resolution)
+	}
+
+	// The fmt block stores a whole number of hertz
+	frequency := float64(ecg.Frequency)
+	if frequency <= 0 || frequency > math.MaxUint16 || frequency != math.Trunc(frequency) {
+		return nil, fmt.Errorf("Invalid frequency: %v", ecg.Frequency)
+	}
+
+	format := ecg.Format
+	if format == 0 {
+		format = SampleFormatRaw
+	}
+	if format != SampleFormatRaw && format != SampleFormatDelta {
+		return nil, fmt.Errorf("Unsupported sample format: %s", format)
+	}
+
+	if ecg.FmtReserved < 0 || ecg.FmtReserved > math.MaxUint16 {
+		return nil, fmt.Errorf("Invalid fmt reserved value: %d", ecg.FmtReserved)
+	}
+
+	fmtBlock := &FmtBlock{
+		Format:     byte(format),
+		Frequency:  uint16(ecg.Frequency),
+		Resolution: uint16(resolution),
+		Flags:      byte(ecg.Flags),
+		Reserved:   uint16(ecg.FmtReserved),
+	}
+
+	// MainsFrequency takes precedence over the mains bit of Flags
+	if ecg.MainsFrequency == 60 {
+		fmtBlock.Flags |= FmtFlagMains60Hz
+	} else {
+		fmtBlock.Flags &^= FmtFlagMains60Hz
+	}
+
+	return fmtBlock, nil
+}
+
+// writeBlock appends a block header, the encoded body v and the block checksum to buf
+func writeBlock(buf *bytes.Buffer, blockId string, v interface{}) error {
+	body := &bytes.Buffer{}
+	err := binary.Write(body, binary.LittleEndian, v)
+	if err != nil {
+		return fmt.Errorf("Error writing %q block: %s", blockId, err.Error())
+	}
+
+	blockHeader := BlockHeader{Length: uint32(body.Len())}
+	copy(blockHeader.BlockId[:], blockId)
+
+	start := buf.Len()
+	binary.Write(buf, binary.LittleEndian, &blockHeader)
+	buf.Write(body.Bytes())
+
+	return binary.Write(buf, binary.LittleEndian, calcChecksum(buf.Bytes()[start:]))
+}
+
+// defaultBlockOrder returns the ids of the blocks Encode writes for ecg when
+// BlockOrder is empty: info when present, fmt, one per present lead, then the
+// id of each of UnknownBlocks
+func defaultBlockOrder(ecg *EcgData) []string {
+	var order []string
+	if ecg.Info != nil {
+		order = append(order, "info")
+	}
+	// Space after word is intended, per spec - cp 2019-2-19
+	order = append(order, "fmt ")
+	for i, ref := range ecg.Samples.leadRefs() {
+		if *ref != nil {
+			order = append(order, leadDefinitions[i].BlockID)
+		}
+	}
+	for _, block := range ecg.UnknownBlocks {
+		order = append(order, block.ID)
+	}
+	return order
+}
+
+// blockWriteOrder returns the ids of order that have a block to write in ecg, each
+// used once per block, followed by the blocks order leaves out in default order
+func blockWriteOrder(ecg *EcgData, order []string) []string {
+	defaults := defaultBlockOrder(ecg)
+	remaining := map[string]int{}
+	for _, id := range defaults {
+		remaining[id]++
+	}
+
+	var result []string
+	for _, list := range [][]string{order, defaults} {
+		for _, id := range list {
+			if remaining[id] > 0 {
+				remaining[id]--
+				result = append(result, id)
+			}
+		}
+	}
+	return result
+}
+
+// nthUnknownBlock returns the block at index n among the blocks of unknown with
+// the given id
+func nthUnknownBlock(unknown []RawBlock, id string, n int) RawBlock {
+	for _, block := range unknown {
+		if block.ID != id {
+			continue
+		}
+		if n == 0 {
+			return block
+		}
+		n--
+	}
+	return RawBlock{}
+}
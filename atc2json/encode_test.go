@@ -0,0 +1,188 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	ecg := &EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		MainsFrequency:      60,
+		Gain:                2000,
+		Format:              SampleFormatRaw,
+		Flags:               0x80 | FmtFlagMains60Hz,
+		FmtFlags:            FmtFlags{MainsIs60Hz: true, Undocumented: 0x80},
+		FmtReserved:         0x1234,
+		FmtExtra:            []byte{1, 2, 3},
+		FileVersion:         EncodeFileVersion,
+		Samples: EcgSamples{
+			LeadI:  []int16{0, 1, -1, 32767, -32768},
+			LeadII: []int16{5, 4, 3, 2, 1},
+			AVF:    []int16{-7},
+		},
+		// Warnings are not encoded but found again when parsing
+		Warnings: []Warning{{Code: WarningLeadLength, Message: "aVF has 4 fewer samples than leadI (1 vs 5)"}},
+	}
+
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	res, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg, res)
+}
+
+func TestEncodeFixture(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	encoded, err := Encode(ecg)
+	assert.Nil(t, err)
+	assert.Equal(t, len(atcData), len(encoded))
+
+	res, err := Parse(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg, res)
+}
+
+func TestEncodeFileLayout(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.RecordingUUID[:], "1285733B-9A84-4349-A845-52FCC436353F")
+
+	// A version 3 file with the info block after the leads, an unknown block between
+	// them and a trailing whole-file checksum
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 3})
+	assert.Nil(t, writeBlock(buf, "fmt ", &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 500, Flags: FmtFlagMains60Hz}))
+	assert.Nil(t, writeBlock(buf, "ecg2", []int16{3, 4}))
+	assert.Nil(t, writeBlock(buf, "note", []byte("hello")))
+	assert.Nil(t, writeBlock(buf, "ecg ", []int16{1, 2}))
+	assert.Nil(t, writeBlock(buf, "info", info))
+	binary.Write(buf, binary.LittleEndian, calcChecksum(buf.Bytes()))
+	atcData := buf.Bytes()
+
+	opts := ParseOptions{KeepUnknownBlocks: true}
+	ecg, _, err := ParseWithOptions(atcData, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, ecg.FileVersion)
+	assert.Equal(t, []string{"fmt ", "ecg2", "note", "ecg ", "info"}, ecg.BlockOrder)
+	assert.True(t, ecg.FileChecksumVerified)
+
+	encoded, err := Encode(ecg)
+	assert.Nil(t, err)
+	assert.Equal(t, atcData, encoded)
+
+	// Converting to JSON and back gives the same file
+	jsonStr, err := ConvertWithOptions(atcData, opts)
+	assert.Nil(t, err)
+	encoded, err = EncodeJSON([]byte(jsonStr))
+	assert.Nil(t, err)
+	assert.Equal(t, atcData, encoded)
+
+	// Blocks missing from BlockOrder follow the listed ones, listed blocks without data are skipped
+	ecg.BlockOrder = []string{"ecg ", "ecg7", "fmt "}
+	ecg.FileChecksumVerified = false
+	encoded, err = Encode(ecg)
+	assert.Nil(t, err)
+	locations, err := Index(encoded)
+	assert.Nil(t, err)
+	var ids []string
+	for _, location := range locations {
+		ids = append(ids, location.ID)
+	}
+	assert.Equal(t, []string{"ecg ", "fmt ", "info", "ecg2", "note"}, ids)
+
+	ecg.FileVersion = -1
+	_, err = Encode(ecg)
+	assert.EqualError(t, err, "Invalid file version: -1")
+}
+
+func TestEncodeInvalidFmt(t *testing.T) {
+	_, err := Encode(&EcgData{Gain: 2000})
+	assert.NotNil(t, err)
+
+	_, err = Encode(&EcgData{Frequency: 299.5, Gain: 2000})
+	assert.EqualError(t, err, "Invalid frequency: 299.5")
+}
+
+func TestEncodeUnknownBlocks(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Samples:   EcgSamples{LeadI: []int16{1, 2}},
+		UnknownBlocks: []RawBlock{
+			{ID: "note", Data: []byte("hello")},
+			{ID: "hr  ", Data: []byte{72, 0}},
+		},
+	}
+
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	res, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.Nil(t, res.UnknownBlocks)
+
+	res, _, err = ParseWithOptions(atcData, ParseOptions{KeepUnknownBlocks: true})
+	assert.Nil(t, err)
+	assert.Equal(t, ecg.UnknownBlocks, res.UnknownBlocks)
+
+	atcData[len(atcData)-ChecksumLength-1]++
+	_, _, err = ParseWithOptions(atcData, ParseOptions{KeepUnknownBlocks: true})
+	assert.IsType(t, &ChecksumError{}, err)
+
+	ecg.UnknownBlocks = []RawBlock{{ID: "toolong"}}
+	_, err = Encode(ecg)
+	assert.NotNil(t, err)
+}
+
+func TestEncodeTwelveLeads(t *testing.T) {
+	ecg := &EcgData{Frequency: 500, AmplitudeResolution: 1000, MainsFrequency: 50, Gain: 1000, Format: SampleFormatRaw, FileVersion: EncodeFileVersion}
+	for i, ref := range ecg.Samples.leadRefs() {
+		*ref = []int16{int16(i), int16(-i)}
+	}
+
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	locations, err := Index(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, "ec12", locations[len(locations)-1].ID)
+
+	res, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg, res)
+	assert.Equal(t, []int16{11, -11}, res.Samples.V6)
+
+	leads := res.Samples.Leads()
+	assert.Len(t, leads, 12)
+	assert.Equal(t, "v1", leads[6].Name)
+	assert.Equal(t, "V1", shortLeadName(leads[6].Name))
+}
+
+func TestEncodeJSON(t *testing.T) {
+	for _, name := range []string{"normal-v2", "delta-v2"} {
+		atcData, err := ioutil.ReadFile("../fixtures/" + name + ".atc")
+		assert.Nil(t, err)
+		jsonStr, err := Convert(atcData)
+		assert.Nil(t, err)
+
+		encoded, err := EncodeJSON([]byte(jsonStr))
+		assert.Nil(t, err)
+		assert.Equal(t, atcData, encoded, name)
+	}
+
+	_, err := EncodeJSON([]byte(`{"frequency":300,"gain":2000,"Info":{"phoneModel":"` + strings.Repeat("x", 33) + `"}}`))
+	assert.EqualError(t, err, "Error reading JSON: Info field phoneModel is 33 bytes, longer than 32")
+
+	_, err = EncodeJSON([]byte(`[]`))
+	assert.NotNil(t, err)
+}
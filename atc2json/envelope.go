@@ -0,0 +1,26 @@
+package atc2json
+
+import "encoding/json"
+
+// SchemaVersion is the output schema version written by ConvertV2.
+// Version 1 is the flat object written by Convert.
+const SchemaVersion = 2
+
+// Envelope wraps the converted recording with the version of its schema
+type Envelope struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	Data          *EcgData `json:"data"`
+}
+
+// ConvertV2 marshals atcData to JSON string wrapped in a versioned Envelope.
+// The data field holds the same object Convert writes.
+func ConvertV2(atcData []byte) (jsonStr string, err error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return "", err
+	}
+
+	ecgData.summarize()
+	output, err := json.Marshal(&Envelope{SchemaVersion: SchemaVersion, Data: ecgData})
+	return string(output), err
+}
@@ -0,0 +1,26 @@
+package atc2json
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestConvertV2(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	v1, err := Convert(atcData)
+	assert.Nil(t, err)
+	v2, err := ConvertV2(atcData)
+	assert.Nil(t, err)
+
+	envelope := struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		Data          json.RawMessage `json:"data"`
+	}{}
+	assert.Nil(t, json.Unmarshal([]byte(v2), &envelope))
+	assert.Equal(t, 2, envelope.SchemaVersion)
+	assert.Equal(t, v1, string(envelope.Data))
+}
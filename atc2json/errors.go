@@ -0,0 +1,26 @@
+package atc2json
+
+import "fmt"
+
+// TruncatedBlockError is returned when the input ends inside a sample block.
+// Expected and Got count samples.
+type TruncatedBlockError struct {
+	BlockID  string
+	Expected int
+	Got      int
+}
+
+func (e *TruncatedBlockError) Error() string {
+	return fmt.Sprintf("Block %q truncated: expected %d samples, got %d", e.BlockID, e.Expected, e.Got)
+}
+
+// UnsupportedVersionError is returned when the file version is newer than
+// ParseOptions.MaxSupportedVersion
+type UnsupportedVersionError struct {
+	Version      uint32
+	MaxSupported uint32
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("Unsupported file version %d, newest supported is %d", e.Version, e.MaxSupported)
+}
@@ -0,0 +1,135 @@
+package atc2json
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fhirMDCSystem  = "urn:oid:2.16.840.1.113883.6.24"
+	fhirUCUMSystem = "http://unitsofmeasure.org"
+
+	// fhirMDCLeadBase is the MDC code of MDC_ECG_ELEC_POTL. Lead codes add the
+	// lead identification number also used by SCP-ECG.
+	fhirMDCLeadBase = 131328
+)
+
+type fhirObservation struct {
+	ResourceType      string           `json:"resourceType"`
+	Identifier        []fhirIdentifier `json:"identifier,omitempty"`
+	Status            string           `json:"status"`
+	Category          []fhirConcept    `json:"category"`
+	Code              fhirConcept      `json:"code"`
+	EffectiveDateTime string           `json:"effectiveDateTime,omitempty"`
+	Device            *fhirReference   `json:"device,omitempty"`
+	Component         []fhirComponent  `json:"component"`
+}
+
+type fhirIdentifier struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+}
+
+type fhirReference struct {
+	Display string `json:"display"`
+}
+
+type fhirCoding struct {
+	System  string `json:"system"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type fhirConcept struct {
+	Coding []fhirCoding `json:"coding"`
+}
+
+type fhirQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+	System string  `json:"system"`
+	Code   string  `json:"code"`
+}
+
+type fhirSampledData struct {
+	Origin     fhirQuantity `json:"origin"`
+	Period     float64      `json:"period"`
+	Factor     float64      `json:"factor"`
+	Dimensions int          `json:"dimensions"`
+	Data       string       `json:"data,omitempty"`
+}
+
+type fhirComponent struct {
+	Code             fhirConcept     `json:"code"`
+	ValueSampledData fhirSampledData `json:"valueSampledData"`
+}
+
+// ToFHIRObservation converts ecg to a FHIR R4 Observation resource with one
+// sampledData component per present lead. Samples are the raw values, scaled to
+// millivolts by factor, and period is the sample interval in milliseconds.
+func ToFHIRObservation(ecg *EcgData) ([]byte, error) {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return nil, fmt.Errorf("No leads to write")
+	}
+	if ecg.Gain <= 0 || ecg.Frequency <= 0 {
+		return nil, fmt.Errorf("Invalid gain %v or frequency %v", ecg.Gain, ecg.Frequency)
+	}
+
+	observation := &fhirObservation{
+		ResourceType: "Observation",
+		Status:       "final",
+		Category: []fhirConcept{{Coding: []fhirCoding{{
+			System: "http://terminology.hl7.org/CodeSystem/observation-category",
+			Code:   "procedure",
+		}}}},
+		Code: fhirConcept{Coding: []fhirCoding{{
+			System:  fhirMDCSystem,
+			Code:    strconv.Itoa(fhirMDCLeadBase),
+			Display: "MDC_ECG_ELEC_POTL",
+		}}},
+	}
+
+	if ecg.Info != nil {
+		info := ecg.Info.ToJSON()
+		if id := ecg.Info.recordingID(); id != "" {
+			observation.Identifier = []fhirIdentifier{{
+				System: "urn:ietf:rfc:3986",
+				Value:  "urn:uuid:" + strings.ToLower(id),
+			}}
+		}
+		if info.PhoneModel != "" {
+			observation.Device = &fhirReference{Display: info.PhoneModel}
+		}
+		if t, err := ecg.Info.RecordedAt(); err == nil {
+			observation.EffectiveDateTime = t.Format(time.RFC3339)
+		}
+	}
+
+	for _, lead := range leads {
+		data := make([]string, len(lead.Samples))
+		for i, s := range lead.Samples {
+			data[i] = strconv.Itoa(int(s))
+		}
+
+		observation.Component = append(observation.Component, fhirComponent{
+			Code: fhirConcept{Coding: []fhirCoding{{
+				System:  fhirMDCSystem,
+				Code:    strconv.Itoa(fhirMDCLeadBase + int(scpLeadIDs[lead.Name])),
+				Display: "MDC_ECG_ELEC_POTL_" + strings.TrimPrefix(aecgLeadCodes[lead.Name], "MDC_ECG_LEAD_"),
+			}}},
+			ValueSampledData: fhirSampledData{
+				Origin:     fhirQuantity{Value: 0, Unit: "mV", System: fhirUCUMSystem, Code: "mV"},
+				Period:     1000 / float64(ecg.Frequency),
+				Factor:     1 / float64(ecg.LeadGain(lead.Name)),
+				Dimensions: 1,
+				Data:       strings.Join(data, " "),
+			},
+		})
+	}
+
+	return json.Marshal(observation)
+}
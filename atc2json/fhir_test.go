@@ -0,0 +1,82 @@
+package atc2json
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestToFHIRObservation(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+	ecg.Samples.LeadI = ecg.Samples.LeadI[:3]
+
+	output, err := ToFHIRObservation(ecg)
+	assert.Nil(t, err)
+
+	var observation map[string]interface{}
+	err = json.Unmarshal(output, &observation)
+	assert.Nil(t, err)
+	assert.Equal(t, "Observation", observation["resourceType"])
+	assert.Equal(t, "2012-04-03T14:17:43-07:00", observation["effectiveDateTime"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"system": "urn:ietf:rfc:3986",
+		"value":  "urn:uuid:1285733b-9a84-4349-a845-52fcc436353f",
+	}}, observation["identifier"])
+
+	components := observation["component"].([]interface{})
+	assert.Equal(t, 1, len(components))
+	component := components[0].(map[string]interface{})
+	coding := component["code"].(map[string]interface{})["coding"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "131329", coding["code"])
+	assert.Equal(t, "MDC_ECG_ELEC_POTL_I", coding["display"])
+
+	sampled := component["valueSampledData"].(map[string]interface{})
+	assert.InDelta(t, 3.3333, sampled["period"], 1e-4)
+	assert.Equal(t, 0.0005, sampled["factor"])
+	assert.Equal(t, float64(1), sampled["dimensions"])
+	assert.Regexp(t, `^-?\d+ -?\d+ -?\d+$`, sampled["data"])
+
+	// The identifier uses the canonical UUID, or the raw value when it is not one
+	for raw, expected := range map[string]string{
+		"{1285733B9A844349A84552FCC436353F}": "urn:uuid:1285733b-9a84-4349-a845-52fcc436353f",
+		"Not-A-UUID":                         "urn:uuid:not-a-uuid",
+	} {
+		ecg.Info.RecordingUUID = [len(ecg.Info.RecordingUUID)]byte{}
+		copy(ecg.Info.RecordingUUID[:], raw)
+		output, err = ToFHIRObservation(ecg)
+		assert.Nil(t, err)
+		assert.Contains(t, string(output), `"value":"`+expected+`"`, raw)
+	}
+}
+
+func TestToFHIRObservationNoLeads(t *testing.T) {
+	_, err := ToFHIRObservation(&EcgData{Frequency: 300, Gain: 2000})
+	assert.NotNil(t, err)
+}
+
+func TestToFHIRObservationLeadGains(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		LeadGains: map[string]float32{"leadII": 1000},
+		Samples:   EcgSamples{LeadI: []int16{1}, LeadII: []int16{2}},
+	}
+	output, err := ToFHIRObservation(ecg)
+	assert.Nil(t, err)
+
+	var observation struct {
+		Component []struct {
+			ValueSampledData struct {
+				Factor float64 `json:"factor"`
+			} `json:"valueSampledData"`
+		} `json:"component"`
+	}
+	assert.Nil(t, json.Unmarshal(output, &observation))
+	assert.Len(t, observation.Component, 2)
+	assert.Equal(t, 0.0005, observation.Component[0].ValueSampledData.Factor)
+	assert.Equal(t, 0.001, observation.Component[1].ValueSampledData.Factor)
+}
@@ -0,0 +1,213 @@
+package atc2json
+
+import "math"
+
+const (
+	// DefaultNotchQ is the quality factor used by NotchFilter and RemoveMainsInterference
+	DefaultNotchQ = 30
+	// BaselineCutoff is the high-pass cutoff in Hz used by RemoveBaseline
+	BaselineCutoff = 0.5
+	// DisplayLowCutoff and DisplayHighCutoff are the diagnostic display band in Hz
+	// used by ApplyDisplayFilter
+	DisplayLowCutoff  = 0.67
+	DisplayHighCutoff = 40
+)
+
+// biquad holds normalized second-order IIR filter coefficients
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+}
+
+// NotchFilter removes mainsFreq from samples using DefaultNotchQ, see NotchFilterQ
+func NotchFilter(samples []int16, sampleRate float32, mainsFreq int) []int16 {
+	return NotchFilterQ(samples, sampleRate, mainsFreq, DefaultNotchQ)
+}
+
+// NotchFilterQ applies a second-order IIR notch at mainsFreq with quality factor q
+// and returns the filtered samples in a new slice. The filter is run forwards and
+// backwards so the result has no phase shift, and each pass starts from the steady
+// state of the edge sample so the ends of the recording do not ring.
+// Samples are returned unchanged if the notch frequency is not below Nyquist.
+func NotchFilterQ(samples []int16, sampleRate float32, mainsFreq int, q float64) []int16 {
+	result := make([]int16, len(samples))
+
+	if mainsFreq <= 0 || float32(mainsFreq) >= sampleRate/2 || q <= 0 || len(samples) == 0 {
+		copy(result, samples)
+		return result
+	}
+
+	filter := newNotch(float64(mainsFreq), float64(sampleRate), q)
+
+	data := toFloat(samples)
+	filter.filtfilt(data)
+
+	for i, v := range data {
+		result[i] = roundInt16(v)
+	}
+	return result
+}
+
+// RemoveBaseline removes baseline wander from samples with a zero phase 0.5 Hz
+// high-pass filter and returns the result in a new slice, saturated to int16
+func RemoveBaseline(samples []int16, sampleRate float32) []int16 {
+	result := make([]int16, len(samples))
+
+	if sampleRate <= 2*BaselineCutoff || len(samples) == 0 {
+		copy(result, samples)
+		return result
+	}
+
+	data := toFloat(samples)
+	newHighpass(BaselineCutoff, float64(sampleRate), math.Sqrt2/2).filtfilt(data)
+
+	for i, v := range data {
+		result[i] = roundInt16(v)
+	}
+	return result
+}
+
+// RemoveBaselineWander high-pass filters every present lead, see RemoveBaseline
+func (ecg *EcgData) RemoveBaselineWander() {
+	for _, lead := range ecg.Samples.leadRefs() {
+		if *lead != nil {
+			*lead = RemoveBaseline(*lead, ecg.Frequency)
+		}
+	}
+}
+
+// BandpassFilter passes the band from low to high Hz with a cascade of second-order
+// Butterworth high-pass and low-pass sections and returns the result in a new
+// slice, saturated to int16. Like NotchFilterQ the cascade runs forwards and
+// backwards from the steady state of the edge samples, so there is no phase shift
+// and no warm-up transient at either end. An edge at or above Nyquist is not
+// applied, and samples are returned unchanged if low is not below high.
+func BandpassFilter(samples []int16, sampleRate float32, low, high float64) []int16 {
+	result := make([]int16, len(samples))
+
+	nyquist := float64(sampleRate) / 2
+	if low >= high || len(samples) == 0 {
+		copy(result, samples)
+		return result
+	}
+
+	data := toFloat(samples)
+	if low > 0 && low < nyquist {
+		newHighpass(low, float64(sampleRate), math.Sqrt2/2).filtfilt(data)
+	}
+	if high > 0 && high < nyquist {
+		newLowpass(high, float64(sampleRate), math.Sqrt2/2).filtfilt(data)
+	}
+
+	for i, v := range data {
+		result[i] = roundInt16(v)
+	}
+	return result
+}
+
+// ApplyDisplayFilter band-pass filters every present lead to the DisplayLowCutoff
+// to DisplayHighCutoff band shown by clinical ECG carts, see BandpassFilter
+func (ecg *EcgData) ApplyDisplayFilter() {
+	for _, lead := range ecg.Samples.leadRefs() {
+		if *lead != nil {
+			*lead = BandpassFilter(*lead, ecg.Frequency, DisplayLowCutoff, DisplayHighCutoff)
+		}
+	}
+}
+
+// newNotch returns a notch filter at freq, see the RBJ audio EQ cookbook
+func newNotch(freq, sampleRate, q float64) biquad {
+	w0 := 2 * math.Pi * freq / sampleRate
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return biquad{
+		b0: 1 / a0,
+		b1: -2 * math.Cos(w0) / a0,
+		b2: 1 / a0,
+		a1: -2 * math.Cos(w0) / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// newLowpass returns a second-order low-pass filter with the given cutoff
+func newLowpass(cutoff, sampleRate, q float64) biquad {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 - math.Cos(w0)) / 2 / a0,
+		b1: (1 - math.Cos(w0)) / a0,
+		b2: (1 - math.Cos(w0)) / 2 / a0,
+		a1: -2 * math.Cos(w0) / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// newHighpass returns a second-order high-pass filter with the given cutoff
+func newHighpass(cutoff, sampleRate, q float64) biquad {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 + math.Cos(w0)) / 2 / a0,
+		b1: -(1 + math.Cos(w0)) / a0,
+		b2: (1 + math.Cos(w0)) / 2 / a0,
+		a1: -2 * math.Cos(w0) / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// RemoveMainsInterference notch filters every present lead at the recording's mains frequency
+func (ecg *EcgData) RemoveMainsInterference() {
+	for _, lead := range ecg.Samples.leadRefs() {
+		if *lead != nil {
+			*lead = NotchFilter(*lead, ecg.Frequency, ecg.MainsFrequency)
+		}
+	}
+}
+
+// apply filters data in place. The filter state is initialized as if the
+// first sample had been constant forever, so the output starts without a
+// step from an implicit zero history.
+func (f biquad) apply(data []float64) {
+	if len(data) == 0 {
+		return
+	}
+
+	x1, x2 := data[0], data[0]
+	y1, y2 := f.dcGain()*data[0], f.dcGain()*data[0]
+
+	for i, x := range data {
+		y := f.b0*x + f.b1*x1 + f.b2*x2 - f.a1*y1 - f.a2*y2
+		x2, x1 = x1, x
+		y2, y1 = y1, y
+		data[i] = y
+	}
+}
+
+// filtfilt runs the filter forwards then backwards over data for zero phase shift
+func (f biquad) filtfilt(data []float64) {
+	f.apply(data)
+	reverse(data)
+	f.apply(data)
+	reverse(data)
+}
+
+// dcGain is the gain of the filter at 0 Hz
+func (f biquad) dcGain() float64 {
+	return (f.b0 + f.b1 + f.b2) / (1 + f.a1 + f.a2)
+}
+
+func toFloat(samples []int16) []float64 {
+	data := make([]float64, len(samples))
+	for i, sample := range samples {
+		data[i] = float64(sample)
+	}
+	return data
+}
+
+func reverse(data []float64) {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+}
@@ -0,0 +1,116 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func sineSamples(n int, sampleRate, freq, amplitude, offset float64) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(math.Round(offset + amplitude*math.Sin(2*math.Pi*freq*float64(i)/sampleRate)))
+	}
+	return samples
+}
+
+func maxAbsDiff(a, b []int16) float64 {
+	var max float64
+	for i := range a {
+		max = math.Max(max, math.Abs(float64(a[i])-float64(b[i])))
+	}
+	return max
+}
+
+func TestNotchFilterRemovesMains(t *testing.T) {
+	mains := sineSamples(3000, 300, 60, 1000, 500)
+	res := NotchFilter(mains, 300, 60)
+	assert.Len(t, res, len(mains))
+
+	flat := make([]int16, 3000)
+	for i := range flat {
+		flat[i] = 500
+	}
+	// Ignore the settling time at each edge
+	assert.True(t, maxAbsDiff(res[500:2500], flat[500:2500]) < 20)
+}
+
+func TestNotchFilterPassesSignal(t *testing.T) {
+	signal := sineSamples(3000, 300, 5, 1000, 0)
+	res := NotchFilter(signal, 300, 50)
+	assert.True(t, maxAbsDiff(res, signal) < 5)
+}
+
+func TestNotchFilterAboveNyquist(t *testing.T) {
+	samples := []int16{1, 2, 3}
+	res := NotchFilter(samples, 100, 60)
+	assert.Equal(t, samples, res)
+	res[0] = 9
+	assert.Equal(t, int16(1), samples[0])
+}
+
+func TestRemoveBaseline(t *testing.T) {
+	signal := sineSamples(6000, 300, 10, 1000, 0)
+	wander := sineSamples(6000, 300, 0.1, 3000, 5000)
+
+	drifting := make([]int16, len(signal))
+	for i := range signal {
+		drifting[i] = signal[i] + wander[i]
+	}
+
+	res := RemoveBaseline(drifting, 300)
+	assert.Len(t, res, len(drifting))
+	assert.True(t, maxAbsDiff(res[600:5400], signal[600:5400]) < 100)
+}
+
+func TestRemoveBaselineFullScaleStep(t *testing.T) {
+	step := make([]int16, 600)
+	for i := range step {
+		step[i] = -32768
+		if i >= 300 {
+			step[i] = 32767
+		}
+	}
+
+	// A full scale step must not wrap around when converted back to int16
+	res := RemoveBaseline(step, 300)
+	assert.True(t, res[299] < -30000, "%d", res[299])
+	assert.True(t, res[300] > 30000, "%d", res[300])
+}
+
+func TestBandpassFilter(t *testing.T) {
+	signal := sineSamples(6000, 500, 10, 1000, 0)
+	wander := sineSamples(6000, 500, 0.1, 3000, 2000)
+	noise := sineSamples(6000, 500, 150, 500, 0)
+
+	noisy := make([]int16, len(signal))
+	for i := range signal {
+		noisy[i] = signal[i] + wander[i] + noise[i]
+	}
+	original := append([]int16{}, noisy...)
+
+	res := BandpassFilter(noisy, 500, DisplayLowCutoff, DisplayHighCutoff)
+	assert.Len(t, res, len(noisy))
+	assert.Equal(t, original, noisy)
+	assert.True(t, maxAbsDiff(res[1000:5000], signal[1000:5000]) < 100, "%v", maxAbsDiff(res[1000:5000], signal[1000:5000]))
+
+	// A constant offset is removed right up to the edges
+	flat := make([]int16, 1000)
+	for i := range flat {
+		flat[i] = 1500
+	}
+	assert.True(t, maxAbsDiff(BandpassFilter(flat, 500, 0.67, 40), make([]int16, 1000)) < 2)
+
+	// The low-pass edge is skipped above Nyquist
+	assert.Equal(t, RemoveBaseline(noisy, 60), BandpassFilter(noisy, 60, 0.5, 40))
+	assert.Equal(t, noisy, BandpassFilter(noisy, 500, 40, 0.67))
+}
+
+func TestApplyDisplayFilter(t *testing.T) {
+	leadI := sineSamples(3000, 300, 0.1, 3000, 2000)
+	ecg := &EcgData{Frequency: 300, Samples: EcgSamples{LeadI: leadI}}
+	ecg.ApplyDisplayFilter()
+	assert.Nil(t, ecg.Samples.LeadII)
+	assert.Equal(t, BandpassFilter(leadI, 300, DisplayLowCutoff, DisplayHighCutoff), ecg.Samples.LeadI)
+	assert.NotEqual(t, leadI, ecg.Samples.LeadI)
+}
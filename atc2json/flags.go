@@ -0,0 +1,32 @@
+package atc2json
+
+// FmtFlags holds the bits of FmtBlock.Flags as named values
+type FmtFlags struct {
+	// EnhancedFilter is FmtFlagEnhancedFilter
+	EnhancedFilter bool `json:"enhancedFilter"`
+	// MainsIs60Hz is FmtFlagMains60Hz, MainsFrequency is 60 when set and 50 otherwise
+	MainsIs60Hz bool `json:"mainsIs60Hz"`
+	// Undocumented holds the set bits without a known meaning
+	Undocumented int `json:"undocumented,omitempty"`
+}
+
+// DecodeFmtFlags splits the flags byte of a fmt block into its known bits
+func DecodeFmtFlags(flags byte) FmtFlags {
+	return FmtFlags{
+		EnhancedFilter: flags&FmtFlagEnhancedFilter != 0,
+		MainsIs60Hz:    flags&FmtFlagMains60Hz != 0,
+		Undocumented:   int(flags &^ (FmtFlagEnhancedFilter | FmtFlagMains60Hz)),
+	}
+}
+
+// Byte returns the flags byte holding f, the inverse of DecodeFmtFlags
+func (f FmtFlags) Byte() byte {
+	flags := byte(f.Undocumented)
+	if f.EnhancedFilter {
+		flags |= FmtFlagEnhancedFilter
+	}
+	if f.MainsIs60Hz {
+		flags |= FmtFlagMains60Hz
+	}
+	return flags
+}
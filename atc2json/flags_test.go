@@ -0,0 +1,35 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFmtFlags(t *testing.T) {
+	for _, tc := range []struct {
+		flags    byte
+		expected FmtFlags
+		mains    int
+	}{
+		{0x00, FmtFlags{}, 50},
+		{0x01, FmtFlags{EnhancedFilter: true}, 50},
+		{0x02, FmtFlags{MainsIs60Hz: true}, 60},
+		{0x03, FmtFlags{EnhancedFilter: true, MainsIs60Hz: true}, 60},
+		{0x0e, FmtFlags{MainsIs60Hz: true, Undocumented: 0x0c}, 60},
+		{0xfd, FmtFlags{EnhancedFilter: true, Undocumented: 0xfc}, 50},
+	} {
+		assert.Equal(t, tc.expected, DecodeFmtFlags(tc.flags), "flags %#02x", tc.flags)
+		assert.Equal(t, tc.flags, tc.expected.Byte(), "flags %#02x", tc.flags)
+
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+		assert.Nil(t, writeBlock(buf, "fmt ", &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 500, Flags: tc.flags}))
+		ecg, err := Parse(buf.Bytes())
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, ecg.FmtFlags, "flags %#02x", tc.flags)
+		assert.Equal(t, tc.mains, ecg.MainsFrequency, "flags %#02x", tc.flags)
+		assert.Equal(t, int(tc.flags), ecg.Flags)
+	}
+}
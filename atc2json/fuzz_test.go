@@ -0,0 +1,56 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzParse checks that no input makes the parsers panic or allocate by a block
+// length the data cannot back. The fixtures and a few crafted headers are the seed
+// corpus; run with go test -fuzz FuzzParse.
+func FuzzParse(f *testing.F) {
+	paths, err := filepath.Glob("../fixtures/*.atc")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		atcData, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(atcData)
+	}
+
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+	for _, blockId := range []string{"fmt ", "info", "ecg ", "ann "} {
+		seed := bytes.NewBuffer(append([]byte{}, header.Bytes()...))
+		binary.Write(seed, binary.LittleEndian, &BlockHeader{BlockId: [4]byte{blockId[0], blockId[1], blockId[2], blockId[3]}, Length: 0xffffffff})
+		seed.Write([]byte{1, 0, 2, 0})
+		f.Add(seed.Bytes())
+	}
+	f.Add(header.Bytes())
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, atcData []byte) {
+		Parse(atcData)
+		ParseWithOptions(atcData, ParseOptions{
+			SkipChecksumErrors: true,
+			KeepUnknownBlocks:  true,
+			StrictLeadLength:   true,
+			ReturnPartial:      true,
+			OnDuplicateLead:    DuplicateLeadAppend,
+			IncludeRawBlocks:   true,
+		})
+		ParseWithOptions(atcData, ParseOptions{ByteOrder: binary.BigEndian})
+		Convert(atcData)
+		Validate(atcData)
+		Index(atcData)
+		LeadsPresent(atcData)
+		ParseInfo(atcData)
+		ParseAll(atcData)
+	})
+}
@@ -0,0 +1,197 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/iotest"
+)
+
+// testFileSpec describes an ATC file built by generateTestFile. Zero values pick
+// the defaults of a KardiaMobile recording.
+type testFileSpec struct {
+	// Frequency defaults to 300 Hz
+	Frequency float32
+	// Gain in LSB/mV defaults to 2000, it is stored as the nearest amplitude resolution
+	Gain float32
+	// MainsFrequency is 50 or 60 Hz, defaulting to 60
+	MainsFrequency int
+	// Format defaults to SampleFormatRaw, delta samples are encoded from Samples
+	Format SampleFormat
+	// FmtExtra is written at the end of the fmt block, as in EcgData
+	FmtExtra []byte
+	// Info is written as the info block when set
+	Info *InfoBlockJSON
+	// Samples holds the leads written, one block per present lead in file order
+	Samples EcgSamples
+	// UnknownBlocks are written between the fmt block and the lead blocks
+	UnknownBlocks []RawBlock
+	// OmitFmt leaves out the fmt block, making the file invalid
+	OmitFmt bool
+}
+
+// generateTestFile builds the ATC file described by spec with Encode
+func generateTestFile(spec testFileSpec) ([]byte, error) {
+	ecg := &EcgData{
+		Frequency:      spec.Frequency,
+		Gain:           spec.Gain,
+		MainsFrequency: 60,
+		Format:         spec.Format,
+		FmtExtra:       spec.FmtExtra,
+		Samples:        spec.Samples,
+		UnknownBlocks:  spec.UnknownBlocks,
+	}
+	if ecg.Frequency == 0 {
+		ecg.Frequency = 300
+	}
+	if ecg.Gain == 0 {
+		ecg.Gain = 2000
+	}
+	if spec.MainsFrequency == 50 {
+		ecg.MainsFrequency = 50
+	}
+
+	if spec.Info != nil {
+		data, err := json.Marshal(spec.Info)
+		if err != nil {
+			return nil, err
+		}
+		ecg.Info = &InfoBlock{}
+		err = ecg.Info.UnmarshalJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Without a fmt block it is written last and cut off
+	ecg.BlockOrder = []string{"info", "fmt "}
+	if spec.OmitFmt {
+		ecg.BlockOrder = []string{"info"}
+	}
+	for _, block := range spec.UnknownBlocks {
+		ecg.BlockOrder = append(ecg.BlockOrder, block.ID)
+	}
+	for i, ref := range ecg.Samples.leadRefs() {
+		if *ref != nil {
+			ecg.BlockOrder = append(ecg.BlockOrder, leadDefinitions[i].BlockID)
+		}
+	}
+
+	atcData, err := Encode(ecg)
+	if err != nil || !spec.OmitFmt {
+		return atcData, err
+	}
+	fmtLength := binary.Size(BlockHeader{}) + binary.Size(FmtBlock{}) + len(spec.FmtExtra) + ChecksumLength
+	return atcData[:len(atcData)-fmtLength], nil
+}
+
+func TestGenerateTestFileGolden(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "2012-04-03T14:17:43-07:00")
+	copy(info.RecordingUUID[:], "1285733B-9A84-4349-A845-52FCC436353F")
+	copy(info.PhoneModel[:], "iPhone4,1")
+
+	for _, tc := range []struct {
+		name     string
+		spec     testFileSpec
+		expected *EcgData
+	}{
+		{
+			name: "defaults",
+			spec: testFileSpec{Samples: EcgSamples{LeadI: []int16{0, 1, -1, 32767, -32768}}},
+			expected: &EcgData{
+				Frequency: 300, AmplitudeResolution: 500, MainsFrequency: 60, Gain: 2000,
+				Format: SampleFormatRaw, Flags: FmtFlagMains60Hz, FmtFlags: FmtFlags{MainsIs60Hz: true},
+				FileVersion: EncodeFileVersion,
+				Samples:     EcgSamples{LeadI: []int16{0, 1, -1, 32767, -32768}},
+			},
+		},
+		{
+			name: "six leads at 500 Hz with info",
+			spec: testFileSpec{
+				Frequency:      500,
+				Gain:           1000,
+				MainsFrequency: 50,
+				Info: &InfoBlockJSON{
+					DateRecorded:  "2012-04-03T14:17:43-07:00",
+					RecordingUUID: "1285733B-9A84-4349-A845-52FCC436353F",
+					PhoneModel:    "iPhone4,1",
+				},
+				Samples: EcgSamples{
+					LeadI: []int16{1, 2}, LeadII: []int16{3, 4}, LeadIII: []int16{2, 2},
+					AVR: []int16{-2, -3}, AVL: []int16{0, 0}, AVF: []int16{2, 3},
+				},
+			},
+			expected: &EcgData{
+				Frequency: 500, AmplitudeResolution: 1000, MainsFrequency: 50, Gain: 1000,
+				Format: SampleFormatRaw, FileVersion: EncodeFileVersion, Info: info,
+				Samples: EcgSamples{
+					LeadI: []int16{1, 2}, LeadII: []int16{3, 4}, LeadIII: []int16{2, 2},
+					AVR: []int16{-2, -3}, AVL: []int16{0, 0}, AVF: []int16{2, 3},
+				},
+			},
+		},
+		{
+			name: "delta samples",
+			spec: testFileSpec{Format: SampleFormatDelta, Samples: EcgSamples{V6: []int16{100, 90, 95, -5}}},
+			expected: &EcgData{
+				Frequency: 300, AmplitudeResolution: 500, MainsFrequency: 60, Gain: 2000,
+				Format: SampleFormatDelta, Flags: FmtFlagMains60Hz, FmtFlags: FmtFlags{MainsIs60Hz: true},
+				FileVersion: EncodeFileVersion,
+				Samples:     EcgSamples{V6: []int16{100, 90, 95, -5}},
+			},
+		},
+		{
+			// Skipping the unknown block must not lose track of the blocks after it
+			name: "unknown blocks before the leads",
+			spec: testFileSpec{
+				Samples:       EcgSamples{LeadI: []int16{7, 8, 9}, LeadII: []int16{4, 5, 6}},
+				UnknownBlocks: []RawBlock{{ID: "ann ", Data: []byte{1, 2, 3}}, {ID: "xtra", Data: make([]byte, 5000)}},
+			},
+			expected: &EcgData{
+				Frequency: 300, AmplitudeResolution: 500, MainsFrequency: 60, Gain: 2000,
+				Format: SampleFormatRaw, Flags: FmtFlagMains60Hz, FmtFlags: FmtFlags{MainsIs60Hz: true},
+				FileVersion: EncodeFileVersion,
+				Samples:     EcgSamples{LeadI: []int16{7, 8, 9}, LeadII: []int16{4, 5, 6}},
+				Warnings: []Warning{
+					{WarningUnknownBlock, `Skipped unknown "ann " block at offset 32`},
+					{WarningUnknownBlock, `Skipped unknown "xtra" block at offset 47`},
+				},
+			},
+		},
+	} {
+		atcData, err := generateTestFile(tc.spec)
+		assert.Nil(t, err, tc.name)
+		assert.Nil(t, Validate(atcData), tc.name)
+
+		ecg, err := Parse(atcData)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.expected, ecg, tc.name)
+
+		// Reading the file in tiny pieces gives the same result
+		ecg, err = ParseReader(iotest.OneByteReader(bytes.NewReader(atcData)))
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.expected, ecg, tc.name)
+	}
+}
+
+func TestGenerateTestFileMissingFmt(t *testing.T) {
+	atcData, err := generateTestFile(testFileSpec{OmitFmt: true, Samples: EcgSamples{LeadI: []int16{1}}})
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, ecg)
+	assert.EqualError(t, err, "Missing fmt block")
+
+	ecg, _, err = ParseWithOptions(atcData, ParseOptions{ReturnPartial: true})
+	assert.Nil(t, ecg)
+	assert.EqualError(t, err, "Missing fmt block")
+}
+
+func TestGenerateTestFileInvalid(t *testing.T) {
+	_, err := generateTestFile(testFileSpec{Gain: 1e7})
+	assert.EqualError(t, err, "Invalid amplitude resolution: 0")
+	_, err = generateTestFile(testFileSpec{UnknownBlocks: []RawBlock{{ID: "toolong"}}})
+	assert.EqualError(t, err, `Invalid block id "toolong"`)
+}
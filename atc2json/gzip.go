@@ -0,0 +1,59 @@
+package atc2json
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DecompressionError is returned when gzip compressed input cannot be decompressed
+type DecompressionError struct {
+	Err error
+}
+
+func (e *DecompressionError) Error() string {
+	return fmt.Sprintf("Error decompressing input: %s", e.Err.Error())
+}
+
+func (e *DecompressionError) Unwrap() error {
+	return e.Err
+}
+
+// gzipReader remembers the last decompression error so that it can be
+// told apart from a parse error on the decompressed data
+type gzipReader struct {
+	r   io.Reader
+	err error
+}
+
+func (g *gzipReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	if err != nil && err != io.EOF {
+		g.err = err
+	}
+	return n, err
+}
+
+// decompress returns a reader over the decompressed input if it starts with
+// the gzip magic number, or over the input unchanged otherwise. ATC files
+// always start with "ALIVE" so the check cannot misfire on them.
+func decompress(input io.Reader) (io.Reader, *gzipReader, error) {
+	buffered := bufio.NewReader(input)
+
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if !bytes.Equal(magic, gzipMagic) {
+		return buffered, nil, nil
+	}
+
+	zr, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, nil, &DecompressionError{Err: err}
+	}
+
+	gz := &gzipReader{r: zr}
+	return gz, gz, nil
+}
@@ -0,0 +1,32 @@
+package atc2json
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseGzip(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	zw.Write(atcData)
+	zw.Close()
+	compressed := buf.Bytes()
+
+	expected, err := Parse(atcData)
+	assert.Nil(t, err)
+	res, err := Parse(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, res)
+
+	_, err = Parse(compressed[:len(compressed)/2])
+	assert.IsType(t, &DecompressionError{}, err)
+
+	_, err = Parse(compressed[:5])
+	assert.IsType(t, &DecompressionError{}, err)
+}
@@ -0,0 +1,123 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// BlockHandler decodes the body of a block into ecg, the recording being parsed.
+// It is only called once the block checksum has been verified, in file order.
+type BlockHandler func(body []byte, ecg *EcgData) error
+
+var (
+	blockHandlersMu sync.RWMutex
+	blockHandlers   = map[string]BlockHandler{}
+)
+
+// RegisterBlockHandler makes parsing pass the body of every block with the given
+// 4 byte id to fn, replacing the handler registered before, including the built-in
+// ones for the fmt, info and lead blocks. Blocks without a handler are skipped or
+// kept in UnknownBlocks. The fields derived from the fmt block are set after all
+// blocks are read, so handlers cannot rely on them. Register handlers from init,
+// before any parsing starts.
+func RegisterBlockHandler(id string, fn BlockHandler) {
+	if len(id) != 4 {
+		panic(fmt.Sprintf("Invalid block id %q, must be 4 bytes", id))
+	}
+	blockHandlersMu.Lock()
+	defer blockHandlersMu.Unlock()
+	blockHandlers[id] = fn
+}
+
+func lookupBlockHandler(id string) (BlockHandler, bool) {
+	blockHandlersMu.RLock()
+	defer blockHandlersMu.RUnlock()
+	fn, ok := blockHandlers[id]
+	return fn, ok
+}
+
+func init() {
+	// Space after word is intended, per spec - cp 2019-2-19
+	RegisterBlockHandler("fmt ", handleFmtBlock)
+	RegisterBlockHandler("info", handleInfoBlock)
+	for _, def := range leadDefinitions {
+		RegisterBlockHandler(def.BlockID, handleLeadBlock(def.BlockID))
+	}
+}
+
+// parseState is the state of the built-in handlers while a recording is parsed
+type parseState struct {
+	opts       ParseOptions
+	order      binary.ByteOrder
+	blockStart int64
+	fmtBlock   *FmtBlock
+	// leadSegments holds the blocks read for each lead, more than one only with DuplicateLeadAppend
+	leadSegments map[string][][]int16
+	// blockOrder lists the ids of the blocks read with a valid checksum, in file order
+	blockOrder []string
+}
+
+func handleFmtBlock(body []byte, ecg *EcgData) error {
+	fmtBlock, extra, err := decodeFmtBlock(body, ecg.parse.order)
+	if err != nil {
+		return err
+	}
+	ecg.parse.fmtBlock = fmtBlock
+	ecg.FmtExtra = extra
+	return nil
+}
+
+func handleInfoBlock(body []byte, ecg *EcgData) error {
+	info := &InfoBlock{}
+	err := readBlock("info", bytes.NewReader(body), info, ecg.parse.order)
+	if err != nil {
+		return err
+	}
+	ecg.Info = info
+	return nil
+}
+
+// handleLeadBlock returns the handler of the lead stored in blocks with the given id.
+// Samples are collected in the parse state, as decoding delta samples needs the fmt
+// block, which may follow the lead blocks.
+func handleLeadBlock(id string) BlockHandler {
+	return func(body []byte, ecg *EcgData) error {
+		samples := decodeSamples(body, ecg.parse.order)
+		// A zero-length lead block was enabled but captured nothing and counts as absent
+		if len(samples) == 0 {
+			ecg.warn(WarningEmptyLead, "Empty %q block at offset %d, lead treated as absent", id, ecg.parse.blockStart)
+			return nil
+		}
+
+		segments := ecg.parse.leadSegments
+		if len(segments[id]) == 0 {
+			segments[id] = [][]int16{samples}
+			return nil
+		}
+		switch ecg.parse.opts.OnDuplicateLead {
+		case DuplicateLeadAppend:
+			segments[id] = append(segments[id], samples)
+		case DuplicateLeadError:
+			return fmt.Errorf("Duplicate %q block at offset %d", id, ecg.parse.blockStart)
+		default:
+			ecg.warn(WarningDuplicateLead, "Duplicate %q block at offset %d replaces the earlier one", id, ecg.parse.blockStart)
+			segments[id] = [][]int16{samples}
+		}
+		return nil
+	}
+}
+
+// decodeSamples returns the int16 samples stored in body in the given byte order,
+// ignoring a trailing odd byte. Little-endian samples may share memory with body.
+func decodeSamples(body []byte, order binary.ByteOrder) []int16 {
+	if order == binary.LittleEndian {
+		return Int16Samples(body)
+	}
+	samples := make([]int16, len(body)/2)
+	for i := range samples {
+		samples[i] = int16(order.Uint16(body[2*i:]))
+	}
+	return samples
+}
@@ -0,0 +1,47 @@
+package atc2json
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegisterBlockHandler(t *testing.T) {
+	defer func() {
+		blockHandlersMu.Lock()
+		delete(blockHandlers, "ann ")
+		blockHandlersMu.Unlock()
+	}()
+
+	atcData, err := Encode(&EcgData{
+		FileVersion:         EncodeFileVersion,
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		Samples:             EcgSamples{LeadI: []int16{1, 2}},
+		UnknownBlocks:       []RawBlock{{ID: "ann ", Data: []byte{1, 2, 3}}},
+	})
+	assert.Nil(t, err)
+
+	var bodies [][]byte
+	RegisterBlockHandler("ann ", func(body []byte, ecg *EcgData) error {
+		bodies = append(bodies, body)
+		ecg.Beats = append(ecg.Beats, Beat{SampleIndex: int(body[0]), Type: "N"})
+		return nil
+	})
+	ecg, _, err := ParseWithOptions(atcData, ParseOptions{KeepUnknownBlocks: true})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{{1, 2, 3}}, bodies)
+	assert.Equal(t, []Beat{{SampleIndex: 1, Type: "N"}}, ecg.Beats)
+	assert.Nil(t, ecg.UnknownBlocks)
+	assert.Equal(t, []int16{1, 2}, ecg.Samples.LeadI)
+
+	// A handler error stops parsing
+	RegisterBlockHandler("ann ", func(body []byte, ecg *EcgData) error {
+		return fmt.Errorf("Bad annotations")
+	})
+	_, err = Parse(atcData)
+	assert.EqualError(t, err, "Bad annotations")
+
+	assert.Panics(t, func() { RegisterBlockHandler("ann", nil) })
+}
@@ -0,0 +1,22 @@
+package atc2json
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// ContentHash returns the hex SHA-256 digest of the samples, ignoring the info block
+// and the fmt block metadata. Every present lead contributes, in the order of
+// EcgSamples.Leads, its name, its sample count as a little-endian uint32 and its
+// samples as little-endian int16 values, so that moving samples between leads
+// changes the hash.
+func (ecg *EcgData) ContentHash() string {
+	h := sha256.New()
+	for _, lead := range ecg.Samples.Leads() {
+		h.Write([]byte(lead.Name))
+		binary.Write(h, binary.LittleEndian, uint32(len(lead.Samples)))
+		binary.Write(h, binary.LittleEndian, lead.Samples)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
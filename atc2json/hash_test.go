@@ -0,0 +1,35 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	hash := ecg.ContentHash()
+	assert.Len(t, hash, 64)
+
+	// Metadata does not affect the hash
+	ecg.Info.PhoneModel[0]++
+	ecg.Gain = 1
+	assert.Equal(t, hash, ecg.ContentHash())
+
+	ecg.Samples.LeadI[100]++
+	assert.NotEqual(t, hash, ecg.ContentHash())
+
+	a := &EcgData{Samples: EcgSamples{LeadI: []int16{1, 2}, LeadII: []int16{}}}
+	b := &EcgData{Samples: EcgSamples{LeadI: []int16{1}, LeadII: []int16{2}}}
+	c := &EcgData{Samples: EcgSamples{LeadII: []int16{1, 2}}}
+	assert.NotEqual(t, a.ContentHash(), b.ContentHash())
+	assert.NotEqual(t, a.ContentHash(), c.ContentHash())
+
+	jsonStr, err := ConvertWithOptions(atcData, ParseOptions{Analyze: true})
+	assert.Nil(t, err)
+	assert.Contains(t, jsonStr, `"contentHash":"`+hash+`"`)
+}
@@ -0,0 +1,200 @@
+package atc2json
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// MinHeartRateDuration is the shortest signal in seconds EstimateHeartRate accepts
+	MinHeartRateDuration = 5
+
+	// QRS detector timing in seconds
+	qrsIntegrationWindow = 0.150
+	qrsRefractoryPeriod  = 0.200
+	qrsLearningPeriod    = 2
+)
+
+// BeatNormal is the Beat type of every detected beat, beats are not classified
+const BeatNormal = "N"
+
+// Beat is a detected QRS complex. SampleIndex is the R peak position in samples.
+type Beat struct {
+	SampleIndex int    `json:"sampleIndex"`
+	Type        string `json:"type"`
+}
+
+// DetectBeats returns the QRS complexes found in samples by the detector behind
+// EstimateHeartRate
+func DetectBeats(samples []int16, sampleRate float32) []Beat {
+	peaks := detectQRS(samples, sampleRate)
+	beats := make([]Beat, len(peaks))
+	for i, peak := range peaks {
+		beats[i] = Beat{SampleIndex: peak, Type: BeatNormal}
+	}
+	return beats
+}
+
+// EstimateHeartRate detects QRS complexes in samples and returns the heart rate in
+// beats per minute from the median RR interval
+func EstimateHeartRate(samples []int16, sampleRate float32) (float64, error) {
+	if sampleRate <= 0 {
+		return 0, fmt.Errorf("Invalid sample rate: %v", sampleRate)
+	}
+	return heartRateFromBeats(DetectBeats(samples, sampleRate), len(samples), sampleRate)
+}
+
+// heartRateFromBeats returns the heart rate in beats per minute from the median RR
+// interval of the beats detected in a signal of n samples
+func heartRateFromBeats(beats []Beat, n int, sampleRate float32) (float64, error) {
+	if sampleRate <= 0 {
+		return 0, fmt.Errorf("Invalid sample rate: %v", sampleRate)
+	}
+
+	duration := float64(n) / float64(sampleRate)
+	if duration < MinHeartRateDuration {
+		return 0, fmt.Errorf("Signal too short to estimate heart rate: %.1f seconds, need at least %d", duration, MinHeartRateDuration)
+	}
+
+	if len(beats) < 2 {
+		return 0, fmt.Errorf("Not enough beats detected to estimate heart rate")
+	}
+
+	intervals := make([]int, len(beats)-1)
+	for i := range intervals {
+		intervals[i] = beats[i+1].SampleIndex - beats[i].SampleIndex
+	}
+	sort.Ints(intervals)
+
+	median := float64(intervals[len(intervals)/2])
+	if len(intervals)%2 == 0 {
+		median = float64(intervals[len(intervals)/2-1]+intervals[len(intervals)/2]) / 2
+	}
+
+	return 60 * float64(sampleRate) / median, nil
+}
+
+// EstimateHeartRate estimates the heart rate from Lead II, or Lead I when Lead II is missing
+func (ecg *EcgData) EstimateHeartRate() (float64, error) {
+	return EstimateHeartRate(ecg.rhythmLead(), ecg.Frequency)
+}
+
+// DetectBeats detects beats in Lead II, or Lead I when Lead II is missing
+func (ecg *EcgData) DetectBeats() []Beat {
+	return DetectBeats(ecg.rhythmLead(), ecg.Frequency)
+}
+
+// rhythmLead returns the lead used for beat detection
+func (ecg *EcgData) rhythmLead() []int16 {
+	if ecg.Samples.LeadII != nil {
+		return ecg.Samples.LeadII
+	}
+	return ecg.Samples.LeadI
+}
+
+// detectQRS returns the sample indices of the R peaks found by a Pan-Tompkins
+// style detector: 5-15 Hz bandpass, derivative, squaring, moving window
+// integration and an adaptive threshold on the integrated signal.
+func detectQRS(samples []int16, sampleRate float32) []int {
+	fs := float64(sampleRate)
+	if len(samples) == 0 || fs <= 0 {
+		return nil
+	}
+
+	// Zero-phase filtering keeps the R peaks at their position in samples
+	bandpassed := toFloat(samples)
+	if 15 < fs/2 {
+		newLowpass(15, fs, math.Sqrt2/2).filtfilt(bandpassed)
+	}
+	newHighpass(5, fs, math.Sqrt2/2).filtfilt(bandpassed)
+
+	at := func(i int) float64 {
+		if i < 0 {
+			return bandpassed[0]
+		}
+		return bandpassed[i]
+	}
+
+	squared := make([]float64, len(bandpassed))
+	for i := range bandpassed {
+		d := (2*at(i) + at(i-1) - at(i-3) - 2*at(i-4)) * fs / 8
+		squared[i] = d * d
+	}
+
+	window := int(qrsIntegrationWindow * fs)
+	if window < 1 {
+		window = 1
+	}
+
+	integrated := make([]float64, len(squared))
+	var total float64
+	for i, v := range squared {
+		total += v
+		if i >= window {
+			total -= squared[i-window]
+		}
+		integrated[i] = total / float64(window)
+	}
+
+	// Initialize the signal and noise levels from the learning period
+	learning := int(qrsLearningPeriod * fs)
+	if learning > len(integrated) {
+		learning = len(integrated)
+	}
+	var max, mean float64
+	for _, v := range integrated[:learning] {
+		max = math.Max(max, v)
+		mean += v / float64(learning)
+	}
+	signalLevel := 0.25 * max
+	noiseLevel := 0.5 * mean
+
+	refractory := int(qrsRefractoryPeriod * fs)
+	var peaks []int
+	var peakValues []float64
+
+	for i := 1; i < len(integrated)-1; i++ {
+		peak := integrated[i]
+		if peak <= integrated[i-1] || peak < integrated[i+1] {
+			continue
+		}
+
+		threshold := noiseLevel + 0.25*(signalLevel-noiseLevel)
+		if peak <= threshold {
+			noiseLevel = 0.125*peak + 0.875*noiseLevel
+			continue
+		}
+
+		last := len(peaks) - 1
+		if last >= 0 && i-peaks[last] <= refractory {
+			// Keep the larger of two peaks within the refractory period
+			if peak > peakValues[last] {
+				peaks[last] = i
+				peakValues[last] = peak
+			}
+			continue
+		}
+
+		peaks = append(peaks, i)
+		peakValues = append(peakValues, peak)
+		signalLevel = 0.125*peak + 0.875*signalLevel
+	}
+
+	// The R peak is the largest bandpassed deflection within the integration window
+	beats := make([]int, len(peaks))
+	for k, peak := range peaks {
+		start := peak - window
+		if start < 0 {
+			start = 0
+		}
+		beats[k] = start
+		for i := start; i <= peak; i++ {
+			if math.Abs(bandpassed[i]) > math.Abs(bandpassed[beats[k]]) {
+				beats[k] = i
+			}
+		}
+	}
+
+	return beats
+}
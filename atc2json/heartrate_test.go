@@ -0,0 +1,64 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+// syntheticEcg returns a train of narrow QRS-like spikes at bpm on a slow baseline
+func syntheticEcg(seconds, sampleRate, bpm float64) []int16 {
+	samples := make([]int16, int(seconds*sampleRate))
+	period := 60 / bpm
+	for i := range samples {
+		t := float64(i) / sampleRate
+		phase := math.Mod(t, period) - period/2
+		samples[i] = int16(2000*math.Exp(-phase*phase/(2*0.01*0.01)) + 200*math.Sin(2*math.Pi*0.3*t))
+	}
+	return samples
+}
+
+func TestEstimateHeartRate(t *testing.T) {
+	for _, bpm := range []float64{45, 75, 150} {
+		res, err := EstimateHeartRate(syntheticEcg(20, 300, bpm), 300)
+		assert.Nil(t, err)
+		assert.InDelta(t, bpm, res, 1, "bpm %v", bpm)
+	}
+}
+
+func TestEstimateHeartRateTooShort(t *testing.T) {
+	_, err := EstimateHeartRate(syntheticEcg(2, 300, 75), 300)
+	assert.NotNil(t, err)
+}
+
+func TestEstimateHeartRateFixture(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	res, err := ecg.EstimateHeartRate()
+	assert.Nil(t, err)
+	assert.True(t, res > 40 && res < 120, "heart rate %v", res)
+}
+
+func TestDetectBeats(t *testing.T) {
+	// At 75 bpm and 300 Hz the R peaks are at 120 + 240k samples
+	beats := DetectBeats(syntheticEcg(20, 300, 75), 300)
+	assert.True(t, len(beats) >= 23, "%d beats", len(beats))
+	for _, beat := range beats {
+		assert.Equal(t, BeatNormal, beat.Type)
+		offset := (beat.SampleIndex - 120) % 240
+		assert.True(t, offset <= 3 || offset >= 237, "beat at %d", beat.SampleIndex)
+	}
+}
+
+func TestConvertBeats(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	jsonStr, err := ConvertWithOptions(atcData, ParseOptions{Analyze: true})
+	assert.Nil(t, err)
+	assert.Contains(t, jsonStr, `"beats":[{"sampleIndex":`)
+}
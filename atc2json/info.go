@@ -0,0 +1,148 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// recordedAtLayouts are the candidate layouts for InfoBlock.DateRecorded
+var recordedAtLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// Legacy recordings write the zone offset without hour padding, e.g. "-7:00"
+var unpaddedOffset = regexp.MustCompile(`([+-])(\d):(\d\d)$`)
+
+// RecordedAt parses DateRecorded. Dates without a zone offset are returned in UTC.
+func (info *InfoBlock) RecordedAt() (time.Time, error) {
+	date := infoString(info.DateRecorded[:])
+	normalized := unpaddedOffset.ReplaceAllString(date, "${1}0${2}:${3}")
+
+	for _, layout := range recordedAtLayouts {
+		t, err := time.Parse(layout, normalized)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unrecognized recording date: %q", date)
+}
+
+// UUID returns RecordingUUID in the canonical lowercase 8-4-4-4-12 form. Surrounding
+// whitespace and braces are trimmed and the 32 hex digits may come without hyphens.
+func (info *InfoBlock) UUID() (string, error) {
+	raw := infoString(info.RecordingUUID[:])
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}") {
+		trimmed = trimmed[1 : len(trimmed)-1]
+	}
+
+	digits := trimmed
+	if len(trimmed) == 36 {
+		for _, i := range []int{8, 13, 18, 23} {
+			if trimmed[i] != '-' {
+				return "", fmt.Errorf("Invalid recording UUID: %q", raw)
+			}
+		}
+		digits = strings.Replace(trimmed, "-", "", -1)
+	}
+	id, err := hex.DecodeString(digits)
+	if err != nil || len(id) != 16 {
+		return "", fmt.Errorf("Invalid recording UUID: %q", raw)
+	}
+
+	h := hex.EncodeToString(id)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+// recordingID returns the canonical UUID of the recording, see UUID, or the raw
+// RecordingUUID when it is not a valid UUID
+func (info *InfoBlock) recordingID() string {
+	id, err := info.UUID()
+	if err != nil {
+		return infoString(info.RecordingUUID[:])
+	}
+	return id
+}
+
+// SampleTime returns the absolute time of sample i, the recording start from
+// InfoBlock.RecordedAt plus i sample periods. It is false when the recording has
+// no parseable start time or no sampling frequency.
+func (ecg *EcgData) SampleTime(i int) (time.Time, bool) {
+	if ecg.Info == nil || ecg.Frequency <= 0 {
+		return time.Time{}, false
+	}
+	start, err := ecg.Info.RecordedAt()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start.Add(sampleOffset(i, ecg.Frequency)), true
+}
+
+// sampleOffset returns the time of sample i from the start of a recording
+// sampled at frequency Hz, rounded to the nanosecond
+func sampleOffset(i int, frequency float32) time.Duration {
+	return time.Duration(math.Round(float64(i) * float64(time.Second) / float64(frequency)))
+}
+
+// ParseInfo reads only the info and fmt blocks of atcData, verifying their
+// checksums, and stops at the first lead block once the fmt block has been found.
+// Samples are never decoded. The info block is nil when the file has none.
+func ParseInfo(atcData []byte) (*InfoBlock, *FmtBlock, error) {
+	return ParseInfoWithOptions(atcData, ParseOptions{})
+}
+
+// ParseInfoWithOptions is ParseInfo reading the blocks in opts.ByteOrder
+func ParseInfoWithOptions(atcData []byte, opts ParseOptions) (*InfoBlock, *FmtBlock, error) {
+	order := opts.byteOrder()
+	leadBlocks := map[string]bool{}
+	for _, def := range leadDefinitions {
+		leadBlocks[def.BlockID] = true
+	}
+	blockHeaderLen := int64(binary.Size(BlockHeader{}))
+
+	var info *InfoBlock
+	var fmtBlock *FmtBlock
+	stop := func(blockId string) bool {
+		return leadBlocks[blockId] && fmtBlock != nil
+	}
+	err := scanBlocksUntil(atcData, order, stop, func(blockId string, offset int64, length uint32) error {
+		var value interface{}
+		switch {
+		case blockId == "fmt ":
+			fmtBlock = &FmtBlock{}
+			value = fmtBlock
+		case blockId == "info":
+			info = &InfoBlock{}
+			value = info
+		default:
+			return nil
+		}
+
+		checksumErr := verifyBlockChecksum(atcData, order, blockId, offset, length)
+		if checksumErr != nil {
+			return checksumErr
+		}
+		body := atcData[offset+blockHeaderLen : offset+blockHeaderLen+int64(length)]
+		return readBlock(blockId, bytes.NewReader(body), value, order)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fmtBlock == nil {
+		return nil, nil, fmt.Errorf("Missing fmt block")
+	}
+	return info, fmtBlock, nil
+}
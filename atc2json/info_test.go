@@ -0,0 +1,128 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRecordedAt(t *testing.T) {
+	cases := map[string]string{
+		"2019-02-19T13:45:02Z":      "2019-02-19T13:45:02Z",
+		"2012-04-03T14:17:43-7:00":  "2012-04-03T14:17:43-07:00",
+		"2012-04-03T14:17:43+10:30": "2012-04-03T14:17:43+10:30",
+		"2012-04-03 14:17:43":       "2012-04-03T14:17:43Z",
+	}
+
+	for date, expected := range cases {
+		info := &InfoBlock{}
+		copy(info.DateRecorded[:], date)
+		res, err := info.RecordedAt()
+		assert.Nil(t, err, date)
+		assert.Equal(t, expected, res.Format(time.RFC3339), date)
+	}
+}
+
+func TestRecordedAtInvalid(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "yesterday")
+	_, err := info.RecordedAt()
+	assert.NotNil(t, err)
+	assert.Equal(t, "", info.ToJSON().RecordedAt)
+}
+
+func TestInfoUUID(t *testing.T) {
+	for _, tc := range []struct {
+		raw      string
+		expected string
+	}{
+		{"1285733B-9A84-4349-A845-52FCC436353F", "1285733b-9a84-4349-a845-52fcc436353f"},
+		{" 1285733b-9a84-4349-a845-52fcc436353f\n", "1285733b-9a84-4349-a845-52fcc436353f"},
+		{"{1285733B-9A84-4349-A845-52FCC436353F}", "1285733b-9a84-4349-a845-52fcc436353f"},
+		{"1285733B9A844349A84552FCC436353F", "1285733b-9a84-4349-a845-52fcc436353f"},
+	} {
+		info := &InfoBlock{}
+		copy(info.RecordingUUID[:], tc.raw)
+		uuid, err := info.UUID()
+		assert.Nil(t, err, tc.raw)
+		assert.Equal(t, tc.expected, uuid)
+		assert.Equal(t, tc.expected, info.ToJSON().UUID)
+	}
+
+	for _, raw := range []string{"", "not-a-uuid", "1285733B-9A84-4349-A845-52FCC436353", "1285733B-9A84-4349-A845_52FCC436353F", "1285733B-9A84-4349-A845-52FCC436353G"} {
+		info := &InfoBlock{}
+		copy(info.RecordingUUID[:], raw)
+		_, err := info.UUID()
+		assert.NotNil(t, err, raw)
+		assert.Equal(t, "", info.ToJSON().UUID)
+	}
+
+	info := &InfoBlock{}
+	copy(info.RecordingUUID[:], "bogus")
+	_, err := info.UUID()
+	assert.EqualError(t, err, `Invalid recording UUID: "bogus"`)
+}
+
+func TestSampleTime(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "2012-04-03T14:17:43-07:00")
+	ecg := &EcgData{Frequency: 300, Info: info}
+
+	start, ok := ecg.SampleTime(0)
+	assert.True(t, ok)
+	assert.Equal(t, "2012-04-03T14:17:43-07:00", start.Format(time.RFC3339Nano))
+	at, ok := ecg.SampleTime(301)
+	assert.True(t, ok)
+	assert.Equal(t, "2012-04-03T14:17:44.003333333-07:00", at.Format(time.RFC3339Nano))
+
+	_, ok = (&EcgData{Frequency: 300}).SampleTime(0)
+	assert.False(t, ok)
+	_, ok = (&EcgData{Info: info}).SampleTime(0)
+	assert.False(t, ok)
+}
+
+func TestParseInfo(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	info, fmtBlock, err := ParseInfo(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg.Info, info)
+	assert.Equal(t, &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 500, Flags: 0x0e}, fmtBlock)
+
+	// Samples are not read, so their checksum is not verified
+	corrupt := append([]byte{}, atcData...)
+	corrupt[1000]++
+	_, _, err = ParseInfo(corrupt)
+	assert.Nil(t, err)
+
+	corrupt[300]++
+	_, _, err = ParseInfo(corrupt)
+	assert.IsType(t, &ChecksumError{}, err)
+
+	_, _, err = ParseInfo(atcData[:288])
+	assert.EqualError(t, err, "Missing fmt block")
+
+	// A truncated ecg block after the fmt and info blocks is never read
+	for _, end := range []int{308 + 6, 308 + 8 + 1000} {
+		info, fmtBlock, err = ParseInfo(atcData[:end])
+		assert.Nil(t, err, "cut at %d", end)
+		assert.Equal(t, ecg.Info, info)
+		assert.Equal(t, uint16(300), fmtBlock.Frequency)
+	}
+}
+
+func BenchmarkParseInfo(b *testing.B) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(atcData)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseInfo(atcData)
+	}
+}
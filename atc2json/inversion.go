@@ -0,0 +1,80 @@
+package atc2json
+
+import "sort"
+
+const (
+	// QRS polarity windows in seconds around each detected R peak
+	qrsPolarityWindow = 0.060
+	qrsBaselineWindow = 0.400
+)
+
+// IsInverted reports whether the QRS complexes found by DetectBeats in samples
+// are mostly negative, their largest deflection from the local baseline pointing
+// down. The baseline is the median of the samples around each beat.
+func IsInverted(samples []int16, sampleRate float32) bool {
+	beats := detectQRS(samples, sampleRate)
+	if len(beats) == 0 {
+		return false
+	}
+
+	half := int(qrsPolarityWindow * float64(sampleRate))
+	baselineHalf := int(qrsBaselineWindow * float64(sampleRate))
+
+	negative := 0
+	for _, beat := range beats {
+		baseline := medianInt16(sampleWindow(samples, beat, baselineHalf))
+
+		var up, down int
+		for _, s := range sampleWindow(samples, beat, half) {
+			deflection := int(s) - baseline
+			if deflection > up {
+				up = deflection
+			}
+			if -deflection > down {
+				down = -deflection
+			}
+		}
+		if down > up {
+			negative++
+		}
+	}
+	return negative*2 > len(beats)
+}
+
+// CorrectInversion negates Lead I and Lead II when IsInverted finds them inverted,
+// as happens when the electrodes are swapped, and records the corrected leads in
+// InvertedLeads. Other leads are left alone as their QRS complexes may normally be
+// negative. It returns the names of the corrected leads.
+func (ecg *EcgData) CorrectInversion() []string {
+	for _, lead := range []struct {
+		name    string
+		samples []int16
+	}{{"leadI", ecg.Samples.LeadI}, {"leadII", ecg.Samples.LeadII}} {
+		if lead.samples == nil || !IsInverted(lead.samples, ecg.Frequency) {
+			continue
+		}
+		for i, s := range lead.samples {
+			lead.samples[i] = clampInt16(-int32(s))
+		}
+		ecg.InvertedLeads = append(ecg.InvertedLeads, lead.name)
+	}
+	return ecg.InvertedLeads
+}
+
+// sampleWindow returns the samples within half samples of center
+func sampleWindow(samples []int16, center, half int) []int16 {
+	start, end := center-half, center+half+1
+	if start < 0 {
+		start = 0
+	}
+	if end > len(samples) {
+		end = len(samples)
+	}
+	return samples[start:end]
+}
+
+func medianInt16(samples []int16) int {
+	sorted := append([]int16{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return int(sorted[len(sorted)/2])
+}
@@ -0,0 +1,29 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestIsInverted(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	assert.False(t, IsInverted(ecg.Samples.LeadI, ecg.Frequency))
+	assert.Nil(t, ecg.CorrectInversion())
+
+	original := append([]int16{}, ecg.Samples.LeadI...)
+	for i, s := range ecg.Samples.LeadI {
+		ecg.Samples.LeadI[i] = -s
+	}
+	assert.True(t, IsInverted(ecg.Samples.LeadI, ecg.Frequency))
+
+	assert.Equal(t, []string{"leadI"}, ecg.CorrectInversion())
+	assert.Equal(t, original, ecg.Samples.LeadI)
+	assert.Equal(t, []string{"leadI"}, ecg.InvertedLeads)
+
+	assert.False(t, IsInverted(make([]int16, 3000), 300))
+}
@@ -0,0 +1,39 @@
+package atc2json
+
+import "encoding/binary"
+
+// fmtLeadResolutionsLength is the size of the per-lead amplitude resolutions an
+// extended fmt block carries after FmtBlock: one uint16 in nV per lead in
+// leadDefinitions order, 0 for leads using FmtBlock.Resolution
+const fmtLeadResolutionsLength = 2 * 12
+
+// decodeLeadGains returns the gains of the present leads of s that an extended fmt
+// block, whose bytes past FmtBlock are extra, gives their own resolution, or nil
+// when there are none
+func decodeLeadGains(extra []byte, order binary.ByteOrder, s *EcgSamples) map[string]float32 {
+	if len(extra) < fmtLeadResolutionsLength {
+		return nil
+	}
+
+	var gains map[string]float32
+	for i, ref := range s.leadRefs() {
+		resolution := order.Uint16(extra[2*i:])
+		if resolution == 0 || *ref == nil {
+			continue
+		}
+		if gains == nil {
+			gains = map[string]float32{}
+		}
+		gains[leadDefinitions[i].Name] = 1e6 / float32(resolution)
+	}
+	return gains
+}
+
+// LeadGain returns the gain in LSB/mV of the named lead, its entry in LeadGains
+// or Gain when it has none
+func (ecg *EcgData) LeadGain(name string) float32 {
+	if gain, ok := ecg.LeadGains[name]; ok {
+		return gain
+	}
+	return ecg.Gain
+}
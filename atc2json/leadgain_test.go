@@ -0,0 +1,55 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLeadGains(t *testing.T) {
+	// Lead II and V1 have their own 1000 nV resolution, V1 is absent
+	extra := make([]byte, fmtLeadResolutionsLength)
+	binary.LittleEndian.PutUint16(extra[2:], 1000)
+	binary.LittleEndian.PutUint16(extra[12:], 1000)
+	atcData, err := generateTestFile(testFileSpec{
+		FmtExtra: extra,
+		Samples:  EcgSamples{LeadI: []int16{2000, -1000}, LeadII: []int16{2000, -1000}},
+	})
+	assert.Nil(t, err)
+
+	// The layout is not marked in the file, so the extra bytes are ignored by default
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+	assert.Nil(t, ecg.LeadGains)
+	assert.Equal(t, float32(2000), ecg.LeadGain("leadII"))
+
+	opts := ParseOptions{LeadResolutions: true}
+	ecg, _, err = ParseWithOptions(atcData, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float32{"leadII": 1000}, ecg.LeadGains)
+	assert.Equal(t, float32(2000), ecg.LeadGain("leadI"))
+	assert.Equal(t, float32(1000), ecg.LeadGain("leadII"))
+
+	mv := ecg.Millivolts()
+	assert.Equal(t, []float32{1, -0.5}, mv.Samples.LeadI)
+	assert.Equal(t, []float32{2, -1}, mv.Samples.LeadII)
+
+	uv := ecg.Microvolts()
+	assert.Equal(t, []int32{1000, -500}, uv.Samples.LeadI)
+	assert.Equal(t, []int32{2000, -1000}, uv.Samples.LeadII)
+
+	// A gain override applies to every lead
+	gain := float32(4000)
+	ecg, _, err = ParseWithOptions(atcData, ParseOptions{LeadResolutions: true, GainOverride: &gain})
+	assert.Nil(t, err)
+	assert.Nil(t, ecg.LeadGains)
+	assert.Equal(t, []float32{0.5, -0.25}, ecg.Millivolts().Samples.LeadII)
+
+	// Without per-lead resolutions the global gain is used
+	atcData, err = generateTestFile(testFileSpec{FmtExtra: []byte{1, 2, 3}, Samples: EcgSamples{LeadII: []int16{2000}}})
+	assert.Nil(t, err)
+	ecg, _, err = ParseWithOptions(atcData, opts)
+	assert.Nil(t, err)
+	assert.Nil(t, ecg.LeadGains)
+	assert.Equal(t, []float32{1}, ecg.Millivolts().Samples.LeadII)
+}
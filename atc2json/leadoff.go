@@ -0,0 +1,67 @@
+package atc2json
+
+import "sort"
+
+// MinLeadOffSeconds is the time a lead must stay railed at SaturationLevel before
+// LeadOffIntervals reports it as disconnected
+const MinLeadOffSeconds = 0.5
+
+// Interval is a stretch of a recording in seconds from its start
+type Interval struct {
+	StartSec float64 `json:"startSec"`
+	EndSec   float64 `json:"endSec"`
+}
+
+// LeadOffIntervals returns the stretches of samples railed at or beyond
+// ±SaturationLevel for at least MinLeadOffSeconds, the saturation recorded while
+// an electrode is disconnected. EndSec is the time just past the last railed sample.
+func LeadOffIntervals(samples []int16, sampleRate float32) []Interval {
+	if sampleRate <= 0 {
+		return nil
+	}
+	minRun := int(MinLeadOffSeconds * float64(sampleRate))
+
+	var intervals []Interval
+	for i := 0; i < len(samples); {
+		if !isClipped(samples[i], SaturationLevel) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(samples) && isClipped(samples[i], SaturationLevel) {
+			i++
+		}
+		if i-start >= minRun {
+			intervals = append(intervals, Interval{
+				StartSec: float64(start) / float64(sampleRate),
+				EndSec:   float64(i) / float64(sampleRate),
+			})
+		}
+	}
+	return intervals
+}
+
+// LeadOffIntervals returns the stretches where any present lead is disconnected,
+// see LeadOffIntervals, with overlapping stretches merged
+func (ecg *EcgData) LeadOffIntervals() []Interval {
+	var intervals []Interval
+	for _, lead := range ecg.Samples.Leads() {
+		intervals = append(intervals, LeadOffIntervals(lead.Samples, ecg.Frequency)...)
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].StartSec < intervals[j].StartSec
+	})
+
+	var merged []Interval
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && interval.StartSec <= merged[last].EndSec {
+			if interval.EndSec > merged[last].EndSec {
+				merged[last].EndSec = interval.EndSec
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
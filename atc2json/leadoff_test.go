@@ -0,0 +1,38 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func railed(n int, v int16) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = v
+	}
+	return samples
+}
+
+func TestLeadOffIntervals(t *testing.T) {
+	signal := syntheticEcg(1, 300, 75)
+
+	var samples []int16
+	samples = append(samples, signal...)
+	samples = append(samples, railed(150, math.MaxInt16)...)
+	samples = append(samples, signal...)
+	// Too short to count
+	samples = append(samples, railed(149, -SaturationLevel)...)
+	samples = append(samples, signal...)
+	samples = append(samples, railed(300, math.MinInt16)...)
+
+	assert.Equal(t, []Interval{{1, 1.5}, {3.5 + 149.0/300, 4.5 + 149.0/300}}, LeadOffIntervals(samples, 300))
+	assert.Nil(t, LeadOffIntervals(signal, 300))
+	assert.Nil(t, LeadOffIntervals(samples, 0))
+
+	ecg := &EcgData{Frequency: 300, Samples: EcgSamples{
+		LeadI:  append(railed(300, math.MaxInt16), signal...),
+		LeadII: append(append(append([]int16{}, signal[:150]...), railed(300, math.MinInt16)...), railed(300, math.MaxInt16)...),
+	}}
+	assert.Equal(t, []Interval{{0, 2.5}}, ecg.LeadOffIntervals())
+}
@@ -0,0 +1,109 @@
+package atc2json
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DeriveLeads fills in Lead III, aVR, aVL and aVF from Lead I and Lead II
+// using Einthoven's and Goldberger's relations. Leads that are already
+// present are left untouched and nothing is derived unless both Lead I
+// and Lead II are present. Derived leads cover the common length of I and II.
+func DeriveLeads(s *EcgSamples) {
+	if s.LeadI == nil || s.LeadII == nil {
+		return
+	}
+
+	n := len(s.LeadI)
+	if len(s.LeadII) < n {
+		n = len(s.LeadII)
+	}
+
+	derive := func(lead *[]int16, calc func(i, ii int32) int32) {
+		if *lead != nil {
+			return
+		}
+		result := make([]int16, n)
+		for k := 0; k < n; k++ {
+			result[k] = clampInt16(calc(int32(s.LeadI[k]), int32(s.LeadII[k])))
+		}
+		*lead = result
+	}
+
+	// III = II - I
+	derive(&s.LeadIII, func(i, ii int32) int32 { return ii - i })
+	// aVR = -(I + II) / 2
+	derive(&s.AVR, func(i, ii int32) int32 { return halve(-(i + ii)) })
+	// aVL = I - II / 2
+	derive(&s.AVL, func(i, ii int32) int32 { return halve(2*i - ii) })
+	// aVF = II - I / 2
+	derive(&s.AVF, func(i, ii int32) int32 { return halve(2*ii - i) })
+}
+
+// halve divides v by two, rounding half away from zero
+func halve(v int32) int32 {
+	if v >= 0 {
+		return (v + 1) / 2
+	}
+	return (v - 1) / 2
+}
+
+// clampInt16 saturates v to the int16 range. Values computed from samples are
+// stored through clampInt16 or roundInt16 so that they saturate instead of
+// wrapping around.
+func clampInt16(v int32) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+// roundInt16 rounds v to the nearest integer and saturates it to the int16 range.
+// NaN becomes 0.
+func roundInt16(v float64) int16 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return int16(math.Round(math.Max(math.Min(v, math.MaxInt16), math.MinInt16)))
+}
+
+// checkLeadLengths returns an error describing every lead whose sample count
+// differs from the first present lead
+func checkLeadLengths(s *EcgSamples) error {
+	mismatches := leadLengthMismatches(s)
+	if mismatches != nil {
+		return fmt.Errorf("Lead lengths differ: %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// leadLengthMismatches describes every lead whose sample count differs from the
+// first present lead
+func leadLengthMismatches(s *EcgSamples) []string {
+	leads := s.Leads()
+	if len(leads) == 0 {
+		return nil
+	}
+
+	reference := leads[0]
+	var mismatches []string
+	for _, lead := range leads[1:] {
+		diff := len(lead.Samples) - len(reference.Samples)
+		if diff == 0 {
+			continue
+		}
+
+		relation := "more"
+		if diff < 0 {
+			relation = "fewer"
+			diff = -diff
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s has %d %s samples than %s (%d vs %d)",
+			lead.Name, diff, relation, reference.Name, len(lead.Samples), len(reference.Samples)))
+	}
+	return mismatches
+}
@@ -0,0 +1,106 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestDeriveLeads(t *testing.T) {
+	s := &EcgSamples{
+		LeadI:  []int16{10, 3, -3, 32767, 0},
+		LeadII: []int16{20, 0, 0, -32768},
+		AVF:    []int16{7},
+	}
+	DeriveLeads(s)
+
+	assert.Equal(t, []int16{10, -3, 3, -32768}, s.LeadIII)
+	assert.Equal(t, []int16{-15, -2, 2, 1}, s.AVR)
+	assert.Equal(t, []int16{0, 3, -3, 32767}, s.AVL)
+	assert.Equal(t, []int16{7}, s.AVF)
+}
+
+func TestDeriveLeadsMissingLeadII(t *testing.T) {
+	s := &EcgSamples{LeadI: []int16{1, 2, 3}}
+	DeriveLeads(s)
+	assert.Nil(t, s.LeadIII)
+	assert.Nil(t, s.AVR)
+}
+
+func TestParseStrictLeadLength(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Samples: EcgSamples{
+			LeadI:   []int16{1, 2, 3, 4},
+			LeadII:  []int16{1, 2, 3},
+			LeadIII: []int16{1, 2, 3, 4},
+			AVR:     []int16{1, 2, 3, 4, 5, 6},
+		},
+	}
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	_, _, err = ParseWithOptions(atcData, ParseOptions{})
+	assert.Nil(t, err)
+
+	_, _, err = ParseWithOptions(atcData, ParseOptions{StrictLeadLength: true})
+	assert.EqualError(t, err, "Lead lengths differ: "+
+		"leadII has 1 fewer samples than leadI (3 vs 4), "+
+		"aVR has 2 more samples than leadI (6 vs 4)")
+
+	ecg.Samples = EcgSamples{LeadI: []int16{1}, LeadII: []int16{2}}
+	atcData, err = Encode(ecg)
+	assert.Nil(t, err)
+	_, _, err = ParseWithOptions(atcData, ParseOptions{StrictLeadLength: true})
+	assert.Nil(t, err)
+}
+
+func TestRoundInt16(t *testing.T) {
+	assert.Equal(t, int16(32767), roundInt16(40000))
+	assert.Equal(t, int16(-32768), roundInt16(-1e9))
+	assert.Equal(t, int16(3), roundInt16(2.5))
+	assert.Equal(t, int16(-3), roundInt16(-2.5))
+	assert.Equal(t, int16(32767), roundInt16(math.Inf(1)))
+	assert.Equal(t, int16(-32768), roundInt16(math.Inf(-1)))
+	assert.Equal(t, int16(0), roundInt16(math.NaN()))
+}
+
+func TestClampInt16(t *testing.T) {
+	for _, tc := range []struct {
+		v        int32
+		expected int16
+	}{
+		{0, 0},
+		{-1, -1},
+		{32767, 32767},
+		{32768, 32767},
+		{-32768, -32768},
+		{-32769, -32768},
+		{math.MaxInt32, 32767},
+		{math.MinInt32, -32768},
+	} {
+		assert.Equal(t, tc.expected, clampInt16(tc.v), "%d", tc.v)
+	}
+}
+
+func TestProcessingSaturates(t *testing.T) {
+	s := EcgSamples{LeadI: []int16{32767, -32768}, LeadII: []int16{-32768, 32767}}
+	DeriveLeads(&s)
+	assert.Equal(t, []int16{-32768, 32767}, s.LeadIII)
+	assert.Equal(t, []int16{1, 1}, s.AVR)
+	assert.Equal(t, []int16{32767, -32768}, s.AVL)
+	assert.Equal(t, []int16{-32768, 32767}, s.AVF)
+
+	// A full scale step overshoots the high-pass filter on both sides
+	step := make([]int16, 600)
+	for i := range step {
+		step[i] = math.MinInt16
+		if i >= 300 {
+			step[i] = math.MaxInt16
+		}
+	}
+	filtered := RemoveBaseline(step, 300)
+	assert.Equal(t, int16(math.MinInt16), filtered[299])
+	assert.True(t, filtered[300] > 0)
+}
@@ -0,0 +1,41 @@
+package atc2json
+
+import "math"
+
+// DetectMainsFrequency measures the power at 50 Hz and 60 Hz in samples with the
+// Goertzel algorithm and returns the frequency with more power, to compare the
+// interference actually present against MainsFrequency. It returns 0 when there
+// are no samples or 60 Hz is not below the Nyquist frequency.
+func DetectMainsFrequency(samples []int16, sampleRate float32) int {
+	if len(samples) == 0 || sampleRate <= 2*60 {
+		return 0
+	}
+
+	var mean float64
+	for _, s := range samples {
+		mean += float64(s)
+	}
+	mean /= float64(len(samples))
+
+	if goertzelPower(samples, mean, 60, float64(sampleRate)) > goertzelPower(samples, mean, 50, float64(sampleRate)) {
+		return 60
+	}
+	return 50
+}
+
+// DetectMainsFrequency detects the mains interference in Lead II, or Lead I when
+// Lead II is missing
+func (ecg *EcgData) DetectMainsFrequency() int {
+	return DetectMainsFrequency(ecg.rhythmLead(), ecg.Frequency)
+}
+
+// goertzelPower returns the power of samples, less mean, at frequency
+func goertzelPower(samples []int16, mean float64, frequency float64, sampleRate float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*frequency/sampleRate)
+	var s1, s2 float64
+	for _, s := range samples {
+		s0 := float64(s) - mean + coeff*s1 - s2
+		s2, s1 = s1, s0
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
@@ -0,0 +1,26 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestDetectMainsFrequency(t *testing.T) {
+	hum := func(strong, weak float64) []int16 {
+		samples := make([]int16, 3000)
+		for i := range samples {
+			t := float64(i) / 300
+			samples[i] = int16(500 + 1000*math.Sin(2*math.Pi*strong*t) + 300*math.Sin(2*math.Pi*weak*t))
+		}
+		return samples
+	}
+
+	assert.Equal(t, 50, DetectMainsFrequency(hum(50, 60), 300))
+	assert.Equal(t, 60, DetectMainsFrequency(hum(60, 50), 300))
+	assert.Equal(t, 0, DetectMainsFrequency(hum(60, 50), 100))
+	assert.Equal(t, 0, DetectMainsFrequency(nil, 300))
+
+	ecg := &EcgData{Frequency: 300, Samples: EcgSamples{LeadI: hum(60, 50), LeadII: hum(50, 60)}}
+	assert.Equal(t, 50, ecg.DetectMainsFrequency())
+}
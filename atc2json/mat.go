@@ -0,0 +1,124 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// MAT-file level 5 data types and array classes
+const (
+	matINT8   = 1
+	matINT16  = 3
+	matINT32  = 5
+	matUINT32 = 6
+	matDOUBLE = 9
+	matMATRIX = 14
+
+	matStructClass = 2
+	matDoubleClass = 6
+	matInt16Class  = 10
+
+	matHeaderTextLength = 116
+	matFieldNameLength  = 32
+	matVariableName     = "ecg"
+)
+
+// matField is a named struct field holding either a double scalar or int16 samples
+type matField struct {
+	name    string
+	scalar  float64
+	samples []int16
+}
+
+// WriteMAT writes ecg to w as a level 5 MAT-file holding a struct named ecg with
+// the fields frequency, gain and mainsFrequency and an int16 column vector per
+// present lead, named after the lead's JSON key.
+func WriteMAT(w io.Writer, ecg *EcgData) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+
+	fields := []matField{
+		{name: "frequency", scalar: float64(ecg.Frequency)},
+		{name: "gain", scalar: float64(ecg.Gain)},
+		{name: "mainsFrequency", scalar: float64(ecg.MainsFrequency)},
+	}
+	for _, lead := range leads {
+		fields = append(fields, matField{name: lead.Name, samples: lead.Samples})
+	}
+
+	names := make([]byte, 0, len(fields)*matFieldNameLength)
+	elements := &bytes.Buffer{}
+	for _, field := range fields {
+		name := make([]byte, matFieldNameLength)
+		copy(name[:matFieldNameLength-1], field.name)
+		names = append(names, name...)
+
+		if field.samples != nil {
+			data := &bytes.Buffer{}
+			binary.Write(data, binary.LittleEndian, field.samples)
+			writeMATArray(elements, matInt16Class, len(field.samples), matINT16, data.Bytes())
+		} else {
+			data := make([]byte, 8)
+			binary.LittleEndian.PutUint64(data, math.Float64bits(field.scalar))
+			writeMATArray(elements, matDoubleClass, 1, matDOUBLE, data)
+		}
+	}
+
+	// Struct body: flags, 1x1 dimensions, name, field name length, field names, fields
+	body := &bytes.Buffer{}
+	writeMATHeader(body, matStructClass, 1, matVariableName)
+	binary.Write(body, binary.LittleEndian, uint32(4<<16|matINT32)) // small data element
+	binary.Write(body, binary.LittleEndian, int32(matFieldNameLength))
+	writeMATElement(body, matINT8, names)
+	body.Write(elements.Bytes())
+
+	header := make([]byte, 128)
+	text := "MATLAB 5.0 MAT-file, Platform: GLNXA64, Created by: atc2json"
+	copy(header, text+strings.Repeat(" ", matHeaderTextLength-len(text)))
+	binary.LittleEndian.PutUint16(header[124:], 0x0100)
+	copy(header[126:], "IM")
+
+	buf := &bytes.Buffer{}
+	buf.Write(header)
+	writeMATElement(buf, matMATRIX, body.Bytes())
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeMATArray writes an unnamed numeric rows x 1 matrix element, as used for struct fields
+func writeMATArray(buf *bytes.Buffer, class uint32, rows int, dataType uint32, data []byte) {
+	body := &bytes.Buffer{}
+	writeMATHeader(body, class, rows, "")
+	writeMATElement(body, dataType, data)
+	writeMATElement(buf, matMATRIX, body.Bytes())
+}
+
+// writeMATHeader writes the array flags, rows x 1 dimensions and name subelements of a matrix
+func writeMATHeader(buf *bytes.Buffer, class uint32, rows int, name string) {
+	flags := make([]byte, 8)
+	binary.LittleEndian.PutUint32(flags, class)
+	writeMATElement(buf, matUINT32, flags)
+
+	dims := make([]byte, 8)
+	binary.LittleEndian.PutUint32(dims, uint32(rows))
+	binary.LittleEndian.PutUint32(dims[4:], 1)
+	writeMATElement(buf, matINT32, dims)
+
+	writeMATElement(buf, matINT8, []byte(name))
+}
+
+// writeMATElement writes a data element tag and data, padded to a multiple of 8 bytes
+func writeMATElement(buf *bytes.Buffer, dataType uint32, data []byte) {
+	binary.Write(buf, binary.LittleEndian, dataType)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if pad := len(data) % 8; pad != 0 {
+		buf.Write(make([]byte, 8-pad))
+	}
+}
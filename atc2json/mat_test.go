@@ -0,0 +1,71 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+// matElement reads the data element at the start of data and returns its type,
+// data and the remaining bytes after padding
+func matElement(data []byte) (uint32, []byte, []byte) {
+	dataType := binary.LittleEndian.Uint32(data)
+	length := int(binary.LittleEndian.Uint32(data[4:]))
+	padded := (length + 7) / 8 * 8
+	return dataType, data[8 : 8+length], data[8+padded:]
+}
+
+func TestWriteMAT(t *testing.T) {
+	ecg := &EcgData{
+		Frequency:      300,
+		Gain:           2000,
+		MainsFrequency: 60,
+		Samples:        EcgSamples{LeadI: []int16{1, -2, 3}},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteMAT(buf, ecg)
+	assert.Nil(t, err)
+
+	out := buf.Bytes()
+	assert.Equal(t, "MATLAB 5.0 MAT-file", string(out[:19]))
+	assert.Equal(t, []byte{0x00, 0x01, 'I', 'M'}, out[124:128])
+
+	dataType, matrix, rest := matElement(out[128:])
+	assert.Equal(t, uint32(matMATRIX), dataType)
+	assert.Equal(t, 0, len(rest))
+
+	_, flags, matrix := matElement(matrix)
+	assert.Equal(t, uint32(matStructClass), binary.LittleEndian.Uint32(flags))
+	_, _, matrix = matElement(matrix)
+	_, name, matrix := matElement(matrix)
+	assert.Equal(t, "ecg", string(name))
+
+	assert.Equal(t, uint32(4<<16|matINT32), binary.LittleEndian.Uint32(matrix))
+	assert.Equal(t, uint32(32), binary.LittleEndian.Uint32(matrix[4:]))
+	_, names, fields := matElement(matrix[8:])
+	assert.Equal(t, 4*32, len(names))
+	assert.Equal(t, "mainsFrequency", string(bytes.TrimRight(names[64:96], "\x00")))
+	assert.Equal(t, "leadI", string(bytes.TrimRight(names[96:128], "\x00")))
+
+	var values [][]byte
+	for len(fields) > 0 {
+		var field []byte
+		_, field, fields = matElement(fields)
+		_, flags, field = matElement(field)
+		_, _, field = matElement(field)
+		_, name, field = matElement(field)
+		assert.Equal(t, 0, len(name))
+		_, data, _ := matElement(field)
+		values = append(values, data)
+		if len(values) == 4 {
+			assert.Equal(t, uint32(matInt16Class), binary.LittleEndian.Uint32(flags))
+		}
+	}
+	assert.Equal(t, 4, len(values))
+	assert.Equal(t, 300.0, math.Float64frombits(binary.LittleEndian.Uint64(values[0])))
+	assert.Equal(t, 60.0, math.Float64frombits(binary.LittleEndian.Uint64(values[2])))
+	assert.Equal(t, []byte{1, 0, 0xfe, 0xff, 3, 0}, values[3])
+}
@@ -0,0 +1,100 @@
+package atc2json
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// MicrovoltUnits is the unit reported for microvolt-scaled samples
+const MicrovoltUnits = "uV"
+
+// EcgMicrovoltData mirrors EcgData with samples as integer microvolts, for
+// consumers without floating point. Lead keys carry a "_uv" suffix.
+type EcgMicrovoltData struct {
+	Frequency           float32             `json:"frequency"`
+	AmplitudeResolution int                 `json:"amplitudeResolution"`
+	MainsFrequency      int                 `json:"mainsFrequency"`
+	Gain                float32             `json:"gain"`
+	LeadGains           map[string]float32  `json:"leadGains,omitempty"`
+	Units               string              `json:"units"`
+	Samples             EcgMicrovoltSamples `json:"samples"`
+	Info                *InfoBlock          `json:"Info"`
+	LeadInfo            map[string]LeadInfo `json:"leadInfo,omitempty"`
+}
+
+type EcgMicrovoltSamples struct {
+	LeadI   []int32 `json:"leadI_uv"`
+	LeadII  []int32 `json:"leadII_uv,omitempty"`
+	LeadIII []int32 `json:"leadIII_uv,omitempty"`
+	AVR     []int32 `json:"aVR_uv,omitempty"`
+	AVL     []int32 `json:"aVL_uv,omitempty"`
+	AVF     []int32 `json:"aVF_uv,omitempty"`
+	V1      []int32 `json:"v1_uv,omitempty"`
+	V2      []int32 `json:"v2_uv,omitempty"`
+	V3      []int32 `json:"v3_uv,omitempty"`
+	V4      []int32 `json:"v4_uv,omitempty"`
+	V5      []int32 `json:"v5_uv,omitempty"`
+	V6      []int32 `json:"v6_uv,omitempty"`
+}
+
+// leadRefs returns pointers to every lead slice in leadDefinitions order
+func (s *EcgMicrovoltSamples) leadRefs() []*[]int32 {
+	return []*[]int32{
+		&s.LeadI, &s.LeadII, &s.LeadIII, &s.AVR, &s.AVL, &s.AVF,
+		&s.V1, &s.V2, &s.V3, &s.V4, &s.V5, &s.V6,
+	}
+}
+
+// Microvolts returns a copy of ecg with every lead in microvolts, computed from
+// AmplitudeResolution, or the resolution of the lead's own LeadGain, in integer
+// arithmetic and rounded half away from zero
+func (ecg *EcgData) Microvolts() *EcgMicrovoltData {
+	result := &EcgMicrovoltData{
+		Frequency:           ecg.Frequency,
+		AmplitudeResolution: ecg.AmplitudeResolution,
+		MainsFrequency:      ecg.MainsFrequency,
+		Gain:                ecg.Gain,
+		LeadGains:           ecg.LeadGains,
+		Units:               MicrovoltUnits,
+		Info:                ecg.Info,
+		LeadInfo:            ecg.CalcLeadInfo(),
+	}
+
+	uvRefs := result.Samples.leadRefs()
+	for i, ref := range ecg.Samples.leadRefs() {
+		resolution := ecg.AmplitudeResolution
+		if gain, ok := ecg.LeadGains[leadDefinitions[i].Name]; ok {
+			resolution = int(math.Round(1e6 / float64(gain)))
+		}
+		*uvRefs[i] = calcMicrovolts(*ref, resolution)
+	}
+	return result
+}
+
+// ConvertMicrovolts marshals atcData to JSON string with samples in integer microvolts
+func ConvertMicrovolts(atcData []byte) (jsonStr string, err error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := json.Marshal(ecgData.Microvolts())
+	return string(output), err
+}
+
+// calcMicrovolts scales samples of resolution nV each to microvolts
+func calcMicrovolts(data []int16, resolution int) []int32 {
+	if data == nil {
+		return nil
+	}
+	result := make([]int32, len(data))
+	for i, sample := range data {
+		nv := int64(sample) * int64(resolution)
+		if nv < 0 {
+			result[i] = int32((nv - 500) / 1000)
+		} else {
+			result[i] = int32((nv + 500) / 1000)
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCalcMicrovolts(t *testing.T) {
+	res := calcMicrovolts([]int16{2000, 1, 3, -1, -3, 32767, -32768}, 500)
+	assert.Equal(t, []int32{1000, 1, 2, -1, -2, 16384, -16384}, res)
+	assert.Nil(t, calcMicrovolts(nil, 500))
+}
+
+func TestConvertWithOptionsUnitMode(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	jsonStr, err := ConvertWithOptions(atcData, ParseOptions{UnitMode: UnitMicrovolts})
+	assert.Nil(t, err)
+	expected, err := ConvertMicrovolts(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, jsonStr)
+
+	var output struct {
+		Units   string             `json:"units"`
+		Samples map[string][]int32 `json:"samples"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(jsonStr), &output))
+	assert.Equal(t, MicrovoltUnits, output.Units)
+	assert.Len(t, output.Samples, 1)
+	assert.Equal(t, calcMicrovolts(ecg.Samples.LeadI, 500), output.Samples["leadI_uv"])
+
+	jsonStr, err = ConvertWithOptions(atcData, ParseOptions{UnitMode: UnitMillivolts})
+	assert.Nil(t, err)
+	expected, err = ConvertMillivolts(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, jsonStr)
+
+	jsonStr, err = ConvertWithOptions(atcData, ParseOptions{})
+	assert.Nil(t, err)
+	expected, err = Convert(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, jsonStr)
+
+	_, err = ConvertWithOptions(atcData, ParseOptions{UnitMode: 7})
+	assert.EqualError(t, err, "Unsupported unit mode: 7")
+	// MarshalWithOptions writes the same JSON for an already parsed recording
+	for _, mode := range []UnitMode{UnitRaw, UnitMicrovolts, UnitMillivolts} {
+		opts := ParseOptions{UnitMode: mode}
+		expected, err = ConvertWithOptions(atcData, opts)
+		assert.Nil(t, err)
+		output, err := MarshalWithOptions(ecg, opts)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(output), "unit mode %d", mode)
+	}
+	_, err = MarshalWithOptions(ecg, ParseOptions{UnitMode: 7})
+	assert.EqualError(t, err, "Unsupported unit mode: 7")
+
+	// EncodeWithOptions writes it to a writer, ending with a newline
+	buf := &bytes.Buffer{}
+	assert.Nil(t, EncodeWithOptions(buf, ecg, ParseOptions{UnitMode: UnitMillivolts}))
+	expected, err = ConvertMillivolts(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, expected+"\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, EncodeWithOptions(buf, ecg, ParseOptions{Indent: "  "}))
+	expected, err = ConvertIndent(atcData, "", "  ")
+	assert.Nil(t, err)
+	assert.Equal(t, expected+"\n", buf.String())
+}
@@ -0,0 +1,172 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// MillivoltUnits is the unit reported for millivolt-scaled samples
+const MillivoltUnits = "mV"
+
+// DefaultMillivoltDecimals is the number of decimal places millivolts are written
+// with unless ParseOptions.MillivoltDecimals says otherwise
+const DefaultMillivoltDecimals = 4
+
+// EcgMillivoltData mirrors EcgData with samples scaled to millivolts.
+// Raw sample counts can be recovered as round(mV * gain).
+type EcgMillivoltData struct {
+	Frequency           float32             `json:"frequency"`
+	AmplitudeResolution int                 `json:"amplitudeResolution"`
+	MainsFrequency      int                 `json:"mainsFrequency"`
+	Gain                float32             `json:"gain"`
+	LeadGains           map[string]float32  `json:"leadGains,omitempty"`
+	Units               string              `json:"units"`
+	Samples             EcgMillivoltSamples `json:"samples"`
+	Info                *InfoBlock          `json:"Info"`
+	LeadInfo            map[string]LeadInfo `json:"leadInfo,omitempty"`
+}
+
+type EcgMillivoltSamples struct {
+	LeadI   []float32 `json:"leadI"`
+	LeadII  []float32 `json:"leadII,omitempty"`
+	LeadIII []float32 `json:"leadIII,omitempty"`
+	AVR     []float32 `json:"aVR,omitempty"`
+	AVL     []float32 `json:"aVL,omitempty"`
+	AVF     []float32 `json:"aVF,omitempty"`
+	V1      []float32 `json:"v1,omitempty"`
+	V2      []float32 `json:"v2,omitempty"`
+	V3      []float32 `json:"v3,omitempty"`
+	V4      []float32 `json:"v4,omitempty"`
+	V5      []float32 `json:"v5,omitempty"`
+	V6      []float32 `json:"v6,omitempty"`
+
+	// decimals is the number of decimal places written by MarshalJSON, or -1 for
+	// the shortest representation of the float32 value
+	decimals int
+}
+
+// leadRefs returns pointers to every lead slice in leadDefinitions order
+func (s *EcgMillivoltSamples) leadRefs() []*[]float32 {
+	return []*[]float32{
+		&s.LeadI, &s.LeadII, &s.LeadIII, &s.AVR, &s.AVL, &s.AVF,
+		&s.V1, &s.V2, &s.V3, &s.V4, &s.V5, &s.V6,
+	}
+}
+
+// MarshalJSON writes every sample rounded to the configured number of decimal
+// places, e.g. 0.5 rather than 0.49999997
+func (s EcgMillivoltSamples) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, ref := range s.leadRefs() {
+		// Lead I is always written, as null when absent
+		if *ref == nil && i > 0 {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Quote(leadDefinitions[i].Name))
+		buf.WriteByte(':')
+		if *ref == nil {
+			buf.WriteString("null")
+			continue
+		}
+
+		buf.WriteByte('[')
+		for j, v := range *ref {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(formatMillivolts(v, s.decimals))
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// formatMillivolts formats v rounded to decimals places without trailing zeros,
+// or as the shortest float32 representation when decimals is negative
+func formatMillivolts(v float32, decimals int) string {
+	if decimals < 0 {
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	scale := math.Pow(10, float64(decimals))
+	rounded := math.Round(float64(v)*scale) / scale
+	if rounded == 0 {
+		// Avoid writing -0
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// Millivolts returns a copy of ecg with every lead divided by its LeadGain, written to
+// JSON with DefaultMillivoltDecimals decimal places
+func (ecg *EcgData) Millivolts() *EcgMillivoltData {
+	return ecg.MillivoltsWithDecimals(DefaultMillivoltDecimals)
+}
+
+// MillivoltsWithDecimals is Millivolts written to JSON with the given number of
+// decimal places, or with full float32 precision when decimals is negative
+func (ecg *EcgData) MillivoltsWithDecimals(decimals int) *EcgMillivoltData {
+	result := &EcgMillivoltData{
+		Frequency:           ecg.Frequency,
+		AmplitudeResolution: ecg.AmplitudeResolution,
+		MainsFrequency:      ecg.MainsFrequency,
+		Gain:                ecg.Gain,
+		LeadGains:           ecg.LeadGains,
+		Units:               MillivoltUnits,
+		Info:                ecg.Info,
+		LeadInfo:            ecg.CalcLeadInfo(),
+	}
+
+	if decimals < 0 {
+		decimals = -1
+	}
+	result.Samples.decimals = decimals
+
+	mvRefs := result.Samples.leadRefs()
+	for i, ref := range ecg.Samples.leadRefs() {
+		*mvRefs[i] = calcMillivolts(*ref, ecg.LeadGain(leadDefinitions[i].Name))
+	}
+	return result
+}
+
+// ConvertMillivolts marshals atcData to JSON string with samples in millivolts
+func ConvertMillivolts(atcData []byte) (jsonStr string, err error) {
+	return ConvertMillivoltsWithOptions(atcData, ParseOptions{})
+}
+
+// ConvertMillivoltsWithOptions is ConvertMillivolts parsing atcData with opts and
+// writing samples with opts.MillivoltDecimals decimal places
+func ConvertMillivoltsWithOptions(atcData []byte, opts ParseOptions) (jsonStr string, err error) {
+	ecgData, _, err := ParseWithOptions(atcData, opts)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := json.Marshal(ecgData.MillivoltsWithDecimals(opts.millivoltDecimals()))
+	return string(output), err
+}
+
+// millivoltDecimals returns MillivoltDecimals, nil meaning DefaultMillivoltDecimals
+func (opts ParseOptions) millivoltDecimals() int {
+	if opts.MillivoltDecimals == nil {
+		return DefaultMillivoltDecimals
+	}
+	return *opts.MillivoltDecimals
+}
+
+func calcMillivolts(data []int16, scale float32) []float32 {
+	if data == nil {
+		return nil
+	}
+	result := make([]float32, len(data))
+	for i, sample := range data {
+		result[i] = float32(sample) / scale
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+package atc2json
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCalcMillivolts(t *testing.T) {
+	data := []int16{2000, 1000, 0, -1000, -2000}
+	scale := float32(2000)
+	res := calcMillivolts(data, scale)
+	assert.Equal(t, []float32{1, 0.5, 0, -0.5, -1}, res, "Arrays should be equal")
+}
+
+func TestCalcMillivoltsRange(t *testing.T) {
+	res := calcMillivolts([]int16{32767, -32768}, 2000)
+	assert.InDelta(t, 16.3835, res[0], 1e-4)
+	assert.InDelta(t, -16.384, res[1], 1e-4)
+	assert.Nil(t, calcMillivolts(nil, 2000))
+}
+
+func TestConvertMillivolts(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	jsonStr, err := ConvertMillivolts(atcData)
+	assert.Nil(t, err)
+	assert.Contains(t, jsonStr, `"gain":2000`)
+	assert.Contains(t, jsonStr, `"units":"mV"`)
+	assert.NotContains(t, jsonStr, `"leadII"`)
+}
+
+func TestFormatMillivolts(t *testing.T) {
+	for _, tc := range []struct {
+		v        float32
+		decimals int
+		expected string
+	}{
+		{0.49999997, 4, "0.5"},
+		{0.49999997, -1, "0.49999997"},
+		{1.23456, 2, "1.23"},
+		{-1.23456, 3, "-1.235"},
+		{-0.00001, 4, "0"},
+		{16.3835, 4, "16.3835"},
+		{2, 0, "2"},
+	} {
+		assert.Equal(t, tc.expected, formatMillivolts(tc.v, tc.decimals), "%v %d", tc.v, tc.decimals)
+	}
+}
+
+func TestMillivoltsDecimals(t *testing.T) {
+	ecg := &EcgData{Gain: 3, Samples: EcgSamples{LeadI: []int16{1, -2, 3}, V2: []int16{}}}
+
+	output, err := json.Marshal(ecg.Millivolts())
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"samples":{"leadI":[0.3333,-0.6667,1],"v2":[]}`)
+
+	output, err = json.Marshal(ecg.MillivoltsWithDecimals(-1))
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"samples":{"leadI":[0.33333334,-0.6666667,1],"v2":[]}`)
+
+	ecg = &EcgData{Gain: 3, Samples: EcgSamples{LeadII: []int16{3}}}
+	output, err = json.Marshal(ecg.Millivolts())
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"samples":{"leadI":null,"leadII":[1]}`)
+
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	decimals := 1
+	jsonStr, err := ConvertMillivoltsWithOptions(atcData, ParseOptions{MillivoltDecimals: &decimals})
+	assert.Nil(t, err)
+	var decoded struct {
+		Samples EcgMillivoltSamples `json:"samples"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(jsonStr), &decoded))
+	assert.Equal(t, 9000, len(decoded.Samples.LeadI))
+	for _, v := range decoded.Samples.LeadI {
+		assert.Equal(t, formatMillivolts(v, 1), formatMillivolts(v, -1))
+	}
+
+	// Zero decimals writes whole millivolts, nil the default
+	ecg = &EcgData{Gain: 1000, Samples: EcgSamples{LeadI: []int16{1499, -1501, 12345, -400}}}
+	decimals = 0
+	output, err = MarshalWithOptions(ecg, ParseOptions{UnitMode: UnitMillivolts, MillivoltDecimals: &decimals})
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"samples":{"leadI":[1,-2,12,0]}`)
+
+	output, err = MarshalWithOptions(ecg, ParseOptions{UnitMode: UnitMillivolts})
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"samples":{"leadI":[1.499,-1.501,12.345,-0.4]}`)
+}
@@ -0,0 +1,19 @@
+package atc2json
+
+// OpenFile parses the ATC file at path from a read-only memory mapping rather than
+// reading it into the heap, where the platform supports it. Parsing copies samples
+// and strings out of the mapping, so the returned EcgData stays valid after the
+// returned closer unmaps the file. The closer must be called once done.
+func OpenFile(path string) (*EcgData, func() error, error) {
+	data, unmap, err := mmapFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ecgData, err := Parse(data)
+	if err != nil {
+		unmap()
+		return nil, nil, err
+	}
+	return ecgData, unmap, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package atc2json
+
+import "io/ioutil"
+
+// mmapFile reads the file at path into memory on platforms without mmap support
+func mmapFile(path string) ([]byte, func() error, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
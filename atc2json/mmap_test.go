@@ -0,0 +1,24 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestOpenFile(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.NoError(t, err)
+	expected, err := Parse(atcData)
+	assert.NoError(t, err)
+
+	ecgData, closer, err := OpenFile("../fixtures/normal-v2.atc")
+	assert.NoError(t, err)
+	assert.NoError(t, closer())
+
+	// Samples remain readable after unmapping
+	assert.Equal(t, expected, ecgData)
+
+	_, _, err = OpenFile("../fixtures/missing.atc")
+	assert.Error(t, err)
+}
@@ -0,0 +1,268 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// ConvertMsgpack is Convert with MessagePack output. Maps use the JSON field names
+// and omitempty rules, samples are packed as MessagePack integers and byte slices,
+// base64 strings in JSON, as binary.
+func ConvertMsgpack(atcData []byte) ([]byte, error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return nil, err
+	}
+
+	ecgData.summarize()
+	buf := &bytes.Buffer{}
+	err = encodeMsgpack(buf, reflect.ValueOf(ecgData))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeMsgpack writes v to buf, following the encoding/json mapping of Go values
+func encodeMsgpack(buf *bytes.Buffer, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Kind() == reflect.Ptr && !v.Type().Implements(jsonMarshalerType) {
+			return encodeMsgpack(buf, v.Elem())
+		}
+	}
+
+	// Types with custom JSON encodings are packed from their decoded JSON
+	if v.Type().Implements(jsonMarshalerType) {
+		decoded, err := decodeMarshaler(v)
+		if err != nil {
+			return err
+		}
+		return encodeMsgpack(buf, decoded)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return encodeMsgpack(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeMsgpackInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			buf.WriteByte(0xcf)
+			binary.Write(buf, binary.BigEndian, v.Uint())
+		} else {
+			writeMsgpackInt(buf, int64(v.Uint()))
+		}
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				writeMsgpackInt(buf, i)
+				return nil
+			}
+			f, err := n.Float64()
+			if err != nil {
+				return err
+			}
+			return encodeMsgpack(buf, reflect.ValueOf(f))
+		}
+		writeMsgpackString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			writeMsgpackBinary(buf, v.Bytes())
+			return nil
+		}
+		writeMsgpackHeader(buf, v.Len(), 0x90, 0xdc)
+		for i := 0; i < v.Len(); i++ {
+			err := encodeMsgpack(buf, v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		writeMsgpackHeader(buf, len(keys), 0x80, 0xde)
+		for _, key := range keys {
+			writeMsgpackString(buf, key)
+			err := encodeMsgpack(buf, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return encodeMsgpackStruct(buf, v)
+	default:
+		return fmt.Errorf("Unsupported MessagePack type: %s", v.Type())
+	}
+	return nil
+}
+
+// encodeMsgpackStruct writes the exported fields of v as a map keyed by their JSON names
+func encodeMsgpackStruct(buf *bytes.Buffer, v reflect.Value) error {
+	names, values := jsonFields(v)
+	writeMsgpackHeader(buf, len(names), 0x80, 0xde)
+	for i, name := range names {
+		writeMsgpackString(buf, name)
+		err := encodeMsgpack(buf, values[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeMarshaler returns the JSON encoding of v decoded into generic values,
+// with numbers as json.Number
+func decodeMarshaler(v reflect.Value) (reflect.Value, error) {
+	data, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	var decoded interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&decoded)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(&decoded).Elem(), nil
+}
+
+// jsonFields returns the JSON names and values of the exported fields of struct v
+// that encoding/json would write, following the omitempty rules
+func jsonFields(v reflect.Value) ([]string, []reflect.Value) {
+	var names []string
+	var values []reflect.Value
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] != "" {
+			name = parts[0]
+		}
+		omitEmpty := false
+		for _, opt := range parts[1:] {
+			omitEmpty = omitEmpty || opt == "omitempty"
+		}
+		if omitEmpty && v.Field(i).IsZero() {
+			continue
+		}
+		if omitEmpty && (v.Field(i).Kind() == reflect.Slice || v.Field(i).Kind() == reflect.Map) && v.Field(i).Len() == 0 {
+			continue
+		}
+
+		names = append(names, name)
+		values = append(values, v.Field(i))
+	}
+	return names, values
+}
+
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	switch {
+	case len(s) < 32:
+		buf.WriteByte(0xa0 | byte(len(s)))
+	case len(s) <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(len(s)))
+	case len(s) <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgpackBinary(buf *bytes.Buffer, b []byte) {
+	switch {
+	case len(b) <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(len(b)))
+	case len(b) <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(len(b)))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	}
+	buf.Write(b)
+}
+
+// writeMsgpackHeader writes an array or map header of n entries, using the fix
+// format below 16 entries and the 16 or 32 bit format otherwise
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix byte, format16 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(format16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(format16 + 1)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
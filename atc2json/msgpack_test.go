@@ -0,0 +1,46 @@
+package atc2json
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestConvertMsgpackSize(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	jsonStr, err := Convert(atcData)
+	assert.Nil(t, err)
+	output, err := ConvertMsgpack(atcData)
+	assert.Nil(t, err)
+
+	// 9000 samples of mostly 2-3 byte integers against 4-5 characters of JSON each
+	assert.True(t, len(output) < len(jsonStr)*2/3, "msgpack %d bytes, json %d bytes", len(output), len(jsonStr))
+	// Keys match the JSON field names, e.g. mainsFrequency as the fixint 60
+	assert.True(t, bytes.Contains(output, []byte("\xaemainsFrequency\x3c")))
+	assert.True(t, bytes.Contains(output, []byte("\xadrecordingUUID\xd9\x24"+"1285733B-9A84-4349-A845-52FCC436353F")))
+}
+
+func TestEncodeMsgpack(t *testing.T) {
+	type sample struct {
+		Name    string  `json:"name"`
+		Skipped int     `json:"skipped,omitempty"`
+		Values  []int16 `json:"values"`
+		OK      bool
+		Ratio   float32 `json:"ratio"`
+	}
+
+	buf := &bytes.Buffer{}
+	err := encodeMsgpack(buf, reflect.ValueOf(&sample{Name: "a", Values: []int16{1, -1, -33, 200, -300}, OK: true, Ratio: 1}))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x84,
+		0xa4, 'n', 'a', 'm', 'e', 0xa1, 'a',
+		0xa6, 'v', 'a', 'l', 'u', 'e', 's', 0x95, 0x01, 0xff, 0xd0, 0xdf, 0xd1, 0x00, 0xc8, 0xd1, 0xfe, 0xd4,
+		0xa2, 'O', 'K', 0xc3,
+		0xa5, 'r', 'a', 't', 'i', 'o', 0xca, 0x3f, 0x80, 0x00, 0x00,
+	}, buf.Bytes())
+}
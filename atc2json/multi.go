@@ -0,0 +1,61 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ParseAll parses atcData holding one or more ATC recordings written back to back,
+// each starting with its own file signature, and returns one EcgData per recording.
+// Recordings are split by walking block lengths, so signature bytes inside a block
+// are never taken for the start of a recording.
+func ParseAll(atcData []byte) ([]*EcgData, error) {
+	var recordings []*EcgData
+	for start := 0; start < len(atcData); {
+		end, err := recordingEnd(atcData, start)
+		if err != nil {
+			return nil, fmt.Errorf("Recording %d at offset %d: %s", len(recordings), start, err.Error())
+		}
+
+		ecgData, err := Parse(atcData[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("Recording %d at offset %d: %s", len(recordings), start, err.Error())
+		}
+		recordings = append(recordings, ecgData)
+		start = end
+	}
+	return recordings, nil
+}
+
+// recordingEnd returns the offset just past the recording starting at start,
+// including any trailing whole-file checksum
+func recordingEnd(atcData []byte, start int) (int, error) {
+	headerLen := binary.Size(AtcFileHeader{})
+	blockHeaderLen := binary.Size(BlockHeader{})
+	isSignature := func(offset int) bool {
+		return hasAtcSignature(atcData[offset:])
+	}
+
+	if len(atcData)-start < headerLen || !isSignature(start) {
+		return 0, fmt.Errorf("Wrong file signature")
+	}
+
+	offset := start + headerLen
+	for offset < len(atcData) && !isSignature(offset) {
+		remaining := len(atcData) - offset
+		if remaining == ChecksumLength || (remaining > ChecksumLength && isSignature(offset+ChecksumLength)) {
+			return offset + ChecksumLength, nil
+		}
+		if len(atcData)-offset < blockHeaderLen {
+			return 0, fmt.Errorf("Truncated block header at offset %d", offset)
+		}
+
+		length := int64(binary.LittleEndian.Uint32(atcData[offset+4 : offset+8]))
+		next := int64(offset) + int64(blockHeaderLen) + length + ChecksumLength
+		if next > int64(len(atcData)) {
+			return 0, fmt.Errorf("Block %q at offset %d with length %d extends past end of file", atcData[offset:offset+4], offset, length)
+		}
+		offset = int(next)
+	}
+	return offset, nil
+}
@@ -0,0 +1,45 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseAll(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	// Samples spelling out the file signature must not split the recording
+	signature := []int16{0x4c41, 0x5649, 0x0045, 0}
+	encoded, err := Encode(&EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		Samples:             EcgSamples{LeadI: signature},
+	})
+	assert.Nil(t, err)
+
+	withChecksum := append([]byte{}, atcData...)
+	withChecksum = binary.LittleEndian.AppendUint32(withChecksum, calcChecksum(atcData))
+
+	var stream []byte
+	for _, recording := range [][]byte{atcData, encoded, withChecksum, atcData} {
+		stream = append(stream, recording...)
+	}
+
+	recordings, err := ParseAll(stream)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(recordings))
+	assert.Equal(t, 9000, len(recordings[0].Samples.LeadI))
+	assert.Equal(t, signature, recordings[1].Samples.LeadI)
+	assert.True(t, recordings[2].FileChecksumVerified)
+	assert.Equal(t, recordings[0], recordings[3])
+
+	_, err = ParseAll(append(stream, atcData[:100]...))
+	assert.EqualError(t, err, `Recording 4 at offset 55016: Block "info" at offset 55028 with length 264 extends past end of file`)
+
+	_, err = ParseAll(append(stream, 'x'))
+	assert.NotNil(t, err)
+}
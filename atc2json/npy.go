@@ -0,0 +1,60 @@
+package atc2json
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	npyMagic     = "\x93NUMPY\x01\x00"
+	npyAlignment = 64
+)
+
+// WriteNPY writes samples to w as a one dimensional little-endian int16 NumPy
+// array in .npy format version 1.0
+func WriteNPY(w io.Writer, samples []int16) error {
+	header := fmt.Sprintf("{'descr': '<i2', 'fortran_order': False, 'shape': (%d,), }", len(samples))
+
+	// The magic, version, header length and header are padded with spaces and a
+	// newline to a multiple of 64 bytes
+	prefixLength := len(npyMagic) + 2
+	total := (prefixLength + len(header) + 1 + npyAlignment - 1) / npyAlignment * npyAlignment
+	header += strings.Repeat(" ", total-prefixLength-len(header)-1) + "\n"
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(npyMagic)
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, samples)
+}
+
+// WriteNPZ writes every present lead of ecg to w as an uncompressed .npz archive,
+// so that numpy.load returns the leads keyed by their JSON names
+func WriteNPZ(w io.Writer, ecg *EcgData) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+
+	archive := zip.NewWriter(w)
+	for _, lead := range leads {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: lead.Name + ".npy", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+
+		err = WriteNPY(f, lead.Samples)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,45 @@
+package atc2json
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriteNPY(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteNPY(buf, []int16{1, -1, 256})
+	assert.Nil(t, err)
+
+	out := buf.Bytes()
+	assert.Equal(t, "\x93NUMPY\x01\x00", string(out[:8]))
+	assert.Equal(t, []byte{118, 0}, out[8:10])
+	assert.Equal(t, "{'descr': '<i2', 'fortran_order': False, 'shape': (3,), }", string(out[10:67]))
+	assert.Equal(t, byte('\n'), out[127])
+	assert.Equal(t, []byte{1, 0, 0xff, 0xff, 0, 1}, out[128:])
+}
+
+func TestWriteNPZ(t *testing.T) {
+	ecg := &EcgData{Samples: EcgSamples{LeadI: []int16{1, 2}, AVF: []int16{3}}}
+
+	buf := &bytes.Buffer{}
+	err := WriteNPZ(buf, ecg)
+	assert.Nil(t, err)
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(archive.File))
+	assert.Equal(t, "leadI.npy", archive.File[0].Name)
+	assert.Equal(t, "aVF.npy", archive.File[1].Name)
+
+	f, err := archive.File[1].Open()
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(f)
+	assert.Nil(t, err)
+
+	expected := &bytes.Buffer{}
+	WriteNPY(expected, []int16{3})
+	assert.Equal(t, expected.Bytes(), data)
+}
@@ -0,0 +1,276 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const parquetMagic = "PAR1"
+
+// Parquet enum values used by the writer
+const (
+	parquetInt32        = 1
+	parquetRequired     = 0
+	parquetOptional     = 1
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// parquetColumn is a single int32 column. Optional columns are null past the end of values.
+type parquetColumn struct {
+	name     string
+	optional bool
+	values   []int32
+}
+
+// WriteParquet writes ecg to w as a Parquet file with a required int32 sample index
+// column and an optional int32 column per present lead, null past the end of shorter
+// leads. The frequency, gain, mains frequency and recording UUID are stored in the
+// footer key-value metadata. Pages are PLAIN encoded and uncompressed.
+func WriteParquet(w io.Writer, ecg *EcgData) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+
+	rows := 0
+	for _, lead := range leads {
+		if len(lead.Samples) > rows {
+			rows = len(lead.Samples)
+		}
+	}
+
+	index := make([]int32, rows)
+	for i := range index {
+		index[i] = int32(i)
+	}
+	columns := []parquetColumn{{name: "sample", values: index}}
+	for _, lead := range leads {
+		values := make([]int32, len(lead.Samples))
+		for i, s := range lead.Samples {
+			values[i] = int32(s)
+		}
+		columns = append(columns, parquetColumn{name: lead.Name, optional: true, values: values})
+	}
+
+	metadata := [][2]string{
+		{"frequency", strconv.FormatFloat(float64(ecg.Frequency), 'g', -1, 32)},
+		{"gain", strconv.FormatFloat(float64(ecg.Gain), 'g', -1, 32)},
+		{"mainsFrequency", strconv.Itoa(ecg.MainsFrequency)},
+	}
+	if ecg.Info != nil {
+		metadata = append(metadata, [2]string{"recordingUUID", ecg.Info.ToJSON().RecordingUUID})
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(parquetMagic)
+
+	chunks := make([]*thriftWriter, len(columns))
+	totalSize := 0
+	for i, column := range columns {
+		offset := buf.Len()
+		writeParquetPage(buf, column, rows)
+		size := buf.Len() - offset
+		totalSize += size
+
+		encodings := []int{parquetPlain}
+		if column.optional {
+			encodings = append(encodings, parquetRLE)
+		}
+
+		meta := &thriftWriter{}
+		meta.i32(1, parquetInt32)
+		meta.i32List(2, encodings)
+		meta.stringList(3, []string{column.name})
+		meta.i32(4, parquetUncompressed)
+		meta.i64(5, int64(rows))
+		meta.i64(6, int64(size))
+		meta.i64(7, int64(size))
+		meta.i64(9, int64(offset))
+
+		chunk := &thriftWriter{}
+		chunk.i64(2, int64(offset))
+		chunk.structField(3, meta)
+		chunks[i] = chunk
+	}
+
+	root := &thriftWriter{}
+	root.binary(4, "schema")
+	root.i32(5, len(columns))
+	schema := []*thriftWriter{root}
+	for _, column := range columns {
+		element := &thriftWriter{}
+		element.i32(1, parquetInt32)
+		if column.optional {
+			element.i32(3, parquetOptional)
+		} else {
+			element.i32(3, parquetRequired)
+		}
+		element.binary(4, column.name)
+		schema = append(schema, element)
+	}
+
+	rowGroup := &thriftWriter{}
+	rowGroup.structList(1, chunks)
+	rowGroup.i64(2, int64(totalSize))
+	rowGroup.i64(3, int64(rows))
+
+	keyValues := make([]*thriftWriter, len(metadata))
+	for i, kv := range metadata {
+		keyValues[i] = &thriftWriter{}
+		keyValues[i].binary(1, kv[0])
+		keyValues[i].binary(2, kv[1])
+	}
+
+	footer := &thriftWriter{}
+	footer.i32(1, 1)
+	footer.structList(2, schema)
+	footer.i64(3, int64(rows))
+	footer.structList(4, []*thriftWriter{rowGroup})
+	footer.structList(5, keyValues)
+	footer.binary(6, "atc2json")
+	footerBytes := footer.bytes()
+
+	buf.Write(footerBytes)
+	binary.Write(buf, binary.LittleEndian, uint32(len(footerBytes)))
+	buf.WriteString(parquetMagic)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeParquetPage writes column as a single uncompressed v1 data page of rows values
+func writeParquetPage(buf *bytes.Buffer, column parquetColumn, rows int) {
+	page := &bytes.Buffer{}
+	if column.optional {
+		// Definition levels: a run of present values followed by a run of nulls,
+		// RLE encoded with a bit width of 1 and prefixed by their length
+		levels := &bytes.Buffer{}
+		for _, run := range []struct {
+			count int
+			level byte
+		}{{len(column.values), 1}, {rows - len(column.values), 0}} {
+			if run.count > 0 {
+				writeUvarint(levels, uint64(run.count)<<1)
+				levels.WriteByte(run.level)
+			}
+		}
+		binary.Write(page, binary.LittleEndian, uint32(levels.Len()))
+		page.Write(levels.Bytes())
+	}
+	binary.Write(page, binary.LittleEndian, column.values)
+
+	dataHeader := &thriftWriter{}
+	dataHeader.i32(1, rows)
+	dataHeader.i32(2, parquetPlain)
+	dataHeader.i32(3, parquetRLE)
+	dataHeader.i32(4, parquetRLE)
+
+	header := &thriftWriter{}
+	header.i32(1, parquetDataPage)
+	header.i32(2, page.Len())
+	header.i32(3, page.Len())
+	header.structField(5, dataHeader)
+
+	buf.Write(header.bytes())
+	buf.Write(page.Bytes())
+}
+
+// thriftWriter encodes a struct in the Thrift compact protocol. Fields must be
+// written in increasing id order.
+type thriftWriter struct {
+	buf     bytes.Buffer
+	fieldID int
+}
+
+func (t *thriftWriter) field(id int, fieldType byte) {
+	delta := id - t.fieldID
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta<<4) | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		writeUvarint(&t.buf, zigzag(int64(id)))
+	}
+	t.fieldID = id
+}
+
+func (t *thriftWriter) i32(id int, v int) {
+	t.field(id, thriftI32)
+	writeUvarint(&t.buf, zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int, v int64) {
+	t.field(id, thriftI64)
+	writeUvarint(&t.buf, zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int, s string) {
+	t.field(id, thriftBinary)
+	writeUvarint(&t.buf, uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) listHeader(id int, size int, elemType byte) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size<<4) | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		writeUvarint(&t.buf, uint64(size))
+	}
+}
+
+func (t *thriftWriter) i32List(id int, values []int) {
+	t.listHeader(id, len(values), thriftI32)
+	for _, v := range values {
+		writeUvarint(&t.buf, zigzag(int64(v)))
+	}
+}
+
+func (t *thriftWriter) stringList(id int, values []string) {
+	t.listHeader(id, len(values), thriftBinary)
+	for _, s := range values {
+		writeUvarint(&t.buf, uint64(len(s)))
+		t.buf.WriteString(s)
+	}
+}
+
+func (t *thriftWriter) structField(id int, s *thriftWriter) {
+	t.field(id, thriftStruct)
+	t.buf.Write(s.bytes())
+}
+
+func (t *thriftWriter) structList(id int, values []*thriftWriter) {
+	t.listHeader(id, len(values), thriftStruct)
+	for _, s := range values {
+		t.buf.Write(s.bytes())
+	}
+}
+
+// bytes returns the encoded struct including its stop field
+func (t *thriftWriter) bytes() []byte {
+	return append(t.buf.Bytes()[:t.buf.Len():t.buf.Len()], 0)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutUvarint(b, v)])
+}
@@ -0,0 +1,55 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWriteParquet(t *testing.T) {
+	info := &InfoBlock{}
+	copy(info.RecordingUUID[:], "1285733B-9A84-4349-A845-52FCC436353F")
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Info:      info,
+		Samples: EcgSamples{
+			LeadI:  []int16{1, -2, 3},
+			LeadII: []int16{4},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteParquet(buf, ecg)
+	assert.Nil(t, err)
+
+	out := buf.Bytes()
+	assert.Equal(t, "PAR1", string(out[:4]))
+	assert.Equal(t, "PAR1", string(out[len(out)-4:]))
+
+	footerLength := int(binary.LittleEndian.Uint32(out[len(out)-8:]))
+	footer := out[len(out)-8-footerLength : len(out)-8]
+	for _, s := range []string{"schema", "sample", "leadI", "leadII", "frequency", "300", "gain", "2000", "1285733B-9A84-4349-A845-52FCC436353F"} {
+		assert.True(t, bytes.Contains(footer, []byte(s)), s)
+	}
+
+	// leadII has a run of one present value and two nulls followed by the value
+	leadII := &bytes.Buffer{}
+	binary.Write(leadII, binary.LittleEndian, uint32(4))
+	leadII.Write([]byte{2, 1, 4, 0})
+	binary.Write(leadII, binary.LittleEndian, int32(4))
+	assert.True(t, bytes.Contains(out, leadII.Bytes()))
+
+	leadI := &bytes.Buffer{}
+	binary.Write(leadI, binary.LittleEndian, []int32{1, -2, 3})
+	assert.True(t, bytes.Contains(out, leadI.Bytes()))
+}
+
+func TestThriftWriter(t *testing.T) {
+	w := &thriftWriter{}
+	w.i32(1, -1)
+	w.binary(2, "ab")
+	w.i64(20, 3)
+	assert.Equal(t, []byte{0x15, 0x01, 0x18, 0x02, 'a', 'b', 0x06, 0x28, 0x06, 0x00}, w.bytes())
+}
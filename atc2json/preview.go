@@ -0,0 +1,55 @@
+package atc2json
+
+import "fmt"
+
+// DefaultPreviewPoints is the preview length used by the CLI, enough for a sparkline
+const DefaultPreviewPoints = 200
+
+// Preview parses atcData and decimates every present lead to at most points samples
+// for thumbnail rendering, keyed by lead name. See DecimateMinMax.
+func Preview(atcData []byte, points int) (map[string][]int16, error) {
+	if points < 2 {
+		return nil, fmt.Errorf("Invalid preview points: %d, need at least 2", points)
+	}
+
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := map[string][]int16{}
+	for _, lead := range ecgData.Samples.Leads() {
+		preview[lead.Name] = DecimateMinMax(lead.Samples, points)
+	}
+	return preview, nil
+}
+
+// DecimateMinMax splits samples into points/2 buckets and keeps the minimum and
+// maximum of each in the order they occur, so spikes survive the decimation rather
+// than being averaged away. Samples no longer than points are returned as a copy.
+func DecimateMinMax(samples []int16, points int) []int16 {
+	if len(samples) <= points {
+		return append([]int16{}, samples...)
+	}
+
+	buckets := points / 2
+	decimated := make([]int16, 0, 2*buckets)
+	for b := 0; b < buckets; b++ {
+		start, end := b*len(samples)/buckets, (b+1)*len(samples)/buckets
+		min, max := start, start
+		for i := start; i < end; i++ {
+			if samples[i] < samples[min] {
+				min = i
+			}
+			if samples[i] > samples[max] {
+				max = i
+			}
+		}
+		if min < max {
+			decimated = append(decimated, samples[min], samples[max])
+		} else {
+			decimated = append(decimated, samples[max], samples[min])
+		}
+	}
+	return decimated
+}
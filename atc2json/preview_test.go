@@ -0,0 +1,35 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecimateMinMax(t *testing.T) {
+	samples := make([]int16, 1000)
+	samples[123] = 5000
+	samples[800] = -7000
+
+	decimated := DecimateMinMax(samples, 10)
+	assert.Equal(t, []int16{0, 5000, 0, 0, 0, 0, 0, 0, -7000, 0}, decimated)
+
+	short := []int16{1, 2, 3}
+	decimated = DecimateMinMax(short, 10)
+	assert.Equal(t, short, decimated)
+	decimated[0] = 9
+	assert.Equal(t, int16(1), short[0])
+}
+
+func TestPreview(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	preview, err := Preview(atcData, DefaultPreviewPoints)
+	assert.Nil(t, err)
+	assert.Len(t, preview, 1)
+	assert.Len(t, preview["leadI"], DefaultPreviewPoints)
+
+	_, err = Preview(atcData, 1)
+	assert.EqualError(t, err, "Invalid preview points: 1, need at least 2")
+}
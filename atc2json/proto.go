@@ -0,0 +1,219 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Protocol Buffers wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// Field numbers of the EcgData message of ecg.proto. The fields of the Info
+// message are numbered from 1 in InfoBlock field order.
+const (
+	protoFrequencyField           = 1
+	protoAmplitudeResolutionField = 2
+	protoMainsFrequencyField      = 3
+	protoGainField                = 4
+	protoFormatField              = 5
+	protoInfoField                = 6
+)
+
+// protoLeadField is the field number of the first lead in ecg.proto. Leads follow
+// in leadDefinitions order.
+const protoLeadField = 10
+
+// ConvertProto parses atcData and encodes it as the EcgData message of ecg.proto
+func ConvertProto(atcData []byte) ([]byte, error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalProto(ecgData), nil
+}
+
+// MarshalProto encodes ecg as the EcgData message of ecg.proto
+func MarshalProto(ecg *EcgData) []byte {
+	buf := &bytes.Buffer{}
+	writeProtoFixed32(buf, protoFrequencyField, math.Float32bits(ecg.Frequency))
+	writeProtoVarint(buf, protoAmplitudeResolutionField, uint64(ecg.AmplitudeResolution))
+	writeProtoVarint(buf, protoMainsFrequencyField, uint64(ecg.MainsFrequency))
+	writeProtoFixed32(buf, protoGainField, math.Float32bits(ecg.Gain))
+	writeProtoVarint(buf, protoFormatField, uint64(ecg.Format))
+
+	if ecg.Info != nil {
+		info := ecg.Info.ToJSON()
+		infoBuf := &bytes.Buffer{}
+		for i, s := range []string{info.DateRecorded, info.RecordingUUID, info.PhoneUDID, info.PhoneModel,
+			info.RecorderSoftware, info.RecorderHardware, info.Location} {
+			writeProtoBytes(infoBuf, i+1, []byte(s))
+		}
+		writeProtoBytes(buf, protoInfoField, infoBuf.Bytes())
+	}
+
+	for i, ref := range ecg.Samples.leadRefs() {
+		if len(*ref) == 0 {
+			continue
+		}
+		packed := &bytes.Buffer{}
+		for _, s := range *ref {
+			writeUvarint(packed, zigzag(int64(s)))
+		}
+		writeProtoBytes(buf, protoLeadField+i, packed.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+// ParseProto decodes an EcgData message of ecg.proto
+func ParseProto(data []byte) (*EcgData, error) {
+	ecg := &EcgData{}
+	leads := ecg.Samples.leadRefs()
+
+	err := walkProto(data, func(field int, wireType int, value uint64, b []byte) error {
+		switch {
+		case field == protoFrequencyField && wireType == protoFixed32:
+			ecg.Frequency = math.Float32frombits(uint32(value))
+		case field == protoAmplitudeResolutionField && wireType == protoVarint:
+			ecg.AmplitudeResolution = int(int32(value))
+		case field == protoMainsFrequencyField && wireType == protoVarint:
+			ecg.MainsFrequency = int(int32(value))
+		case field == protoGainField && wireType == protoFixed32:
+			ecg.Gain = math.Float32frombits(uint32(value))
+		case field == protoFormatField && wireType == protoVarint:
+			ecg.Format = SampleFormat(int32(value))
+		case field == protoInfoField && wireType == protoBytes:
+			info := &InfoBlock{}
+			fields := [][]byte{info.DateRecorded[:], info.RecordingUUID[:], info.PhoneUDID[:], info.PhoneModel[:],
+				info.RecorderSoftware[:], info.RecorderHardware[:], info.Location[:]}
+			err := walkProto(b, func(field int, wireType int, value uint64, b []byte) error {
+				if field >= 1 && field <= len(fields) && wireType == protoBytes {
+					copy(fields[field-1], b)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ecg.Info = info
+		case field >= protoLeadField && field < protoLeadField+len(leads):
+			lead := leads[field-protoLeadField]
+			if *lead == nil {
+				*lead = []int16{}
+			}
+			// Repeated fields may be packed or one value per field
+			if wireType == protoVarint {
+				*lead = append(*lead, int16(unzigzag(value)))
+				return nil
+			}
+			if wireType != protoBytes {
+				return fmt.Errorf("Invalid wire type %d for field %d", wireType, field)
+			}
+			for len(b) > 0 {
+				v, n := binary.Uvarint(b)
+				if n <= 0 {
+					return fmt.Errorf("Invalid packed varint in field %d", field)
+				}
+				*lead = append(*lead, int16(unzigzag(v)))
+				b = b[n:]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ecg, nil
+}
+
+// walkProto calls fn for every field of a message. value holds varint and fixed
+// values, b the contents of length delimited fields.
+func walkProto(data []byte, fn func(field int, wireType int, value uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("Invalid field key")
+		}
+		data = data[n:]
+		field, wireType := int(key>>3), int(key&7)
+
+		var value uint64
+		var b []byte
+		switch wireType {
+		case protoVarint:
+			value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("Invalid varint in field %d", field)
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return fmt.Errorf("Truncated field %d", field)
+			}
+			value = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return fmt.Errorf("Truncated field %d", field)
+			}
+			value = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return fmt.Errorf("Truncated field %d", field)
+			}
+			b = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("Unsupported wire type %d in field %d", wireType, field)
+		}
+
+		err := fn(field, wireType, value, b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeProtoKey(buf *bytes.Buffer, field int, wireType int) {
+	writeUvarint(buf, uint64(field)<<3|uint64(wireType))
+}
+
+// writeProtoVarint writes a varint field, omitting zero values as proto3 does
+func writeProtoVarint(buf *bytes.Buffer, field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	writeProtoKey(buf, field, protoVarint)
+	writeUvarint(buf, v)
+}
+
+func writeProtoFixed32(buf *bytes.Buffer, field int, v uint32) {
+	if v == 0 {
+		return
+	}
+	writeProtoKey(buf, field, protoFixed32)
+	binary.Write(buf, binary.LittleEndian, v)
+}
+
+func writeProtoBytes(buf *bytes.Buffer, field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	writeProtoKey(buf, field, protoBytes)
+	writeUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,107 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestConvertProto(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	output, err := ConvertProto(atcData)
+	assert.Nil(t, err)
+
+	decoded, err := ParseProto(output)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg.Frequency, decoded.Frequency)
+	assert.Equal(t, ecg.AmplitudeResolution, decoded.AmplitudeResolution)
+	assert.Equal(t, ecg.MainsFrequency, decoded.MainsFrequency)
+	assert.Equal(t, ecg.Gain, decoded.Gain)
+	assert.Equal(t, ecg.Format, decoded.Format)
+	assert.Equal(t, ecg.Info.ToJSON(), decoded.Info.ToJSON())
+	assert.Equal(t, ecg.Samples, decoded.Samples)
+
+	// Zigzag packing keeps small samples to one or two bytes each
+	assert.True(t, len(output) < 2*len(ecg.Samples.LeadI)+400)
+}
+
+func TestParseProtoWireFormat(t *testing.T) {
+	// frequency = 300, lead_ii packed [1, -1, 64] and an unpacked lead_ii value -2
+	data := []byte{
+		0x0d, 0x00, 0x00, 0x96, 0x43,
+		0x5a, 0x04, 0x02, 0x01, 0x80, 0x01,
+		0x58, 0x03,
+	}
+
+	ecg, err := ParseProto(data)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(300), ecg.Frequency)
+	assert.Equal(t, []int16{1, -1, 64, -2}, ecg.Samples.LeadII)
+	assert.Nil(t, ecg.Samples.LeadI)
+
+	_, err = ParseProto(data[:8])
+	assert.NotNil(t, err)
+}
+
+// protoFields returns the field numbers of message in ecg.proto by field name
+func protoFields(t *testing.T, schema string, message string) map[string]int {
+	body := regexp.MustCompile(`(?s)message ` + message + ` \{(.*?)\n\}`).FindStringSubmatch(schema)
+	if !assert.NotNil(t, body, "message %s", message) {
+		return nil
+	}
+	fields := map[string]int{}
+	for _, m := range regexp.MustCompile(`(\w+) = (\d+);`).FindAllStringSubmatch(body[1], -1) {
+		fields[m[1]], _ = strconv.Atoi(m[2])
+	}
+	return fields
+}
+
+func TestProtoSchema(t *testing.T) {
+	schema, err := ioutil.ReadFile("ecg.proto")
+	assert.Nil(t, err)
+
+	expected := map[string]int{
+		"frequency":            protoFrequencyField,
+		"amplitude_resolution": protoAmplitudeResolutionField,
+		"mains_frequency":      protoMainsFrequencyField,
+		"gain":                 protoGainField,
+		"format":               protoFormatField,
+		"info":                 protoInfoField,
+	}
+	for i, def := range leadDefinitions {
+		name := strings.ToLower(def.Label)
+		if strings.HasPrefix(def.Name, "lead") {
+			name = "lead_" + name
+		}
+		expected[name] = protoLeadField + i
+	}
+	assert.Equal(t, expected, protoFields(t, string(schema), "EcgData"))
+
+	// MarshalProto writes each info string under its schema field number
+	info := &InfoBlock{}
+	copy(info.DateRecorded[:], "date_recorded")
+	copy(info.RecordingUUID[:], "recording_uuid")
+	copy(info.PhoneUDID[:], "phone_udid")
+	copy(info.PhoneModel[:], "phone_model")
+	copy(info.RecorderSoftware[:], "recorder_software")
+	copy(info.RecorderHardware[:], "recorder_hardware")
+	copy(info.Location[:], "location")
+
+	written := map[string]int{}
+	err = walkProto(MarshalProto(&EcgData{Info: info}), func(field int, wireType int, value uint64, b []byte) error {
+		assert.Equal(t, protoInfoField, field)
+		return walkProto(b, func(field int, wireType int, value uint64, b []byte) error {
+			written[string(b)] = field
+			return nil
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, protoFields(t, string(schema), "Info"), written)
+}
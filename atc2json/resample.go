@@ -0,0 +1,70 @@
+package atc2json
+
+import (
+	"fmt"
+	"math"
+)
+
+// Resample converts samples recorded at from Hz to to Hz by linear interpolation.
+// When downsampling the signal is first low-pass filtered below the new Nyquist
+// frequency. The result has round(len * to/from) samples and keeps the first and
+// last samples unchanged. Samples are copied unchanged if either rate is not positive.
+func Resample(samples []int16, from, to float32) []int16 {
+	if from <= 0 || to <= 0 || from == to {
+		result := make([]int16, len(samples))
+		copy(result, samples)
+		return result
+	}
+
+	n := len(samples)
+	m := int(math.Round(float64(n) * float64(to) / float64(from)))
+	result := make([]int16, m)
+	if n == 0 || m == 0 {
+		return result
+	}
+
+	data := toFloat(samples)
+	if to < from {
+		newLowpass(0.45*float64(to), float64(from), math.Sqrt2/2).filtfilt(data)
+	}
+
+	if m == 1 {
+		result[0] = samples[0]
+		return result
+	}
+
+	// Output samples are spread evenly between the first and last input sample
+	step := float64(n-1) / float64(m-1)
+	for k := range result {
+		pos := float64(k) * step
+		i := int(pos)
+		if i >= n-1 {
+			result[k] = roundInt16(data[n-1])
+			continue
+		}
+		frac := pos - float64(i)
+		result[k] = roundInt16(data[i]*(1-frac) + data[i+1]*frac)
+	}
+
+	result[0] = samples[0]
+	result[m-1] = samples[n-1]
+	return result
+}
+
+// ResampleTo resamples every present lead to freq and updates Frequency. The
+// recording is left unchanged when freq or Frequency is not positive.
+func (ecg *EcgData) ResampleTo(freq float32) error {
+	if freq <= 0 {
+		return fmt.Errorf("Invalid frequency: %v", freq)
+	}
+	if ecg.Frequency <= 0 {
+		return fmt.Errorf("Invalid frequency: %v", ecg.Frequency)
+	}
+	for _, lead := range ecg.Samples.leadRefs() {
+		if *lead != nil {
+			*lead = Resample(*lead, ecg.Frequency, freq)
+		}
+	}
+	ecg.Frequency = freq
+	return nil
+}
@@ -0,0 +1,42 @@
+package atc2json
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestResampleUpsample(t *testing.T) {
+	res := Resample([]int16{0, 10, 20, 30}, 100, 200)
+	assert.Equal(t, []int16{0, 4, 9, 13, 17, 21, 26, 30}, res)
+}
+
+func TestResampleDownsample(t *testing.T) {
+	samples := sineSamples(3000, 300, 5, 1000, 0)
+	res := Resample(samples, 300, 250)
+	assert.Len(t, res, 2500)
+	assert.Equal(t, samples[0], res[0])
+	assert.Equal(t, samples[len(samples)-1], res[len(res)-1])
+
+	expected := sineSamples(2500, 250, 5, 1000, 0)
+	assert.True(t, maxAbsDiff(res[50:2450], expected[50:2450]) < 30)
+}
+
+func TestResampleTo(t *testing.T) {
+	ecg := &EcgData{Frequency: 300, Samples: EcgSamples{LeadI: make([]int16, 9000), AVF: make([]int16, 300)}}
+	assert.Nil(t, ecg.ResampleTo(250))
+	assert.Equal(t, float32(250), ecg.Frequency)
+	assert.Len(t, ecg.Samples.LeadI, 7500)
+	assert.Len(t, ecg.Samples.AVF, 250)
+	assert.Nil(t, ecg.Samples.LeadII)
+
+	// An invalid rate leaves the recording untouched
+	for _, freq := range []float32{0, -250} {
+		assert.EqualError(t, ecg.ResampleTo(freq), fmt.Sprintf("Invalid frequency: %v", freq))
+		assert.Equal(t, float32(250), ecg.Frequency)
+		assert.Len(t, ecg.Samples.LeadI, 7500)
+	}
+	ecg.Frequency = 0
+	assert.EqualError(t, ecg.ResampleTo(250), "Invalid frequency: 0")
+	assert.Equal(t, float32(0), ecg.Frequency)
+}
@@ -0,0 +1,226 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	scpSectionHeaderLength = 16
+	scpProtocolVersion     = 20
+	scpSectionCount        = 12
+)
+
+// scpLeadIDs maps lead names to the lead identification codes of SCP-ECG section 3
+var scpLeadIDs = map[string]byte{
+	"leadI":   1,
+	"leadII":  2,
+	"v1":      3,
+	"v2":      4,
+	"v3":      5,
+	"v4":      6,
+	"v5":      7,
+	"v6":      8,
+	"leadIII": 61,
+	"aVR":     62,
+	"aVL":     63,
+	"aVF":     64,
+}
+
+// WriteSCP writes ecg to w as an SCP-ECG (EN 1064) record with sections 0, 1, 3 and 6.
+// Rhythm data is stored uncompressed, without Huffman encoding or differences.
+func WriteSCP(w io.Writer, ecg *EcgData) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+	if len(leads) > 255 {
+		return fmt.Errorf("Too many leads for SCP-ECG: %d", len(leads))
+	}
+
+	if ecg.Gain <= 0 || ecg.Frequency <= 0 {
+		return fmt.Errorf("Invalid gain %v or frequency %v", ecg.Gain, ecg.Frequency)
+	}
+	multiplier := math.Round(1e6 / float64(ecg.Gain))
+	interval := math.Round(1e6 / float64(ecg.Frequency))
+	if multiplier < 1 || multiplier > math.MaxUint16 || interval < 1 || interval > math.MaxUint16 {
+		return fmt.Errorf("Gain %v or frequency %v out of range for SCP-ECG", ecg.Gain, ecg.Frequency)
+	}
+
+	// Section 1, patient and acquisition data
+	section1 := &bytes.Buffer{}
+	writeSCPTag(section1, 2, scpString("")) // patient ID is mandatory but unknown
+	writeSCPTag(section1, 14, scpDeviceID(ecg))
+	if ecg.Info != nil {
+		info := ecg.Info.ToJSON()
+		writeSCPTag(section1, 31, scpString(info.RecordingUUID))
+		if t, err := ecg.Info.RecordedAt(); err == nil {
+			date := make([]byte, 4)
+			binary.LittleEndian.PutUint16(date, uint16(t.Year()))
+			date[2] = byte(t.Month())
+			date[3] = byte(t.Day())
+			writeSCPTag(section1, 25, date)
+			writeSCPTag(section1, 26, []byte{byte(t.Hour()), byte(t.Minute()), byte(t.Second())})
+		}
+	}
+	writeSCPTag(section1, 255, nil)
+
+	// Section 3, lead definitions. All leads are recorded simultaneously.
+	section3 := &bytes.Buffer{}
+	section3.WriteByte(byte(len(leads)))
+	section3.WriteByte(0x04 | byte(len(leads)<<3))
+	for _, lead := range leads {
+		binary.Write(section3, binary.LittleEndian, uint32(1))
+		binary.Write(section3, binary.LittleEndian, uint32(len(lead.Samples)))
+		section3.WriteByte(scpLeadIDs[lead.Name])
+	}
+
+	// Section 6, rhythm data
+	section6 := &bytes.Buffer{}
+	binary.Write(section6, binary.LittleEndian, uint16(multiplier))
+	binary.Write(section6, binary.LittleEndian, uint16(interval))
+	section6.WriteByte(0) // no difference encoding
+	section6.WriteByte(0) // no bimodal compression
+	for _, lead := range leads {
+		if len(lead.Samples)*2 > math.MaxUint16 {
+			return fmt.Errorf("Lead %s too long for SCP-ECG: %d samples", lead.Name, len(lead.Samples))
+		}
+		binary.Write(section6, binary.LittleEndian, uint16(len(lead.Samples)*2))
+	}
+	for _, lead := range leads {
+		binary.Write(section6, binary.LittleEndian, lead.Samples)
+	}
+
+	bodies := map[int][]byte{
+		1: section1.Bytes(),
+		3: section3.Bytes(),
+		6: section6.Bytes(),
+	}
+
+	// Lay out the sections after the 6 byte record header and the pointer section
+	section0Length := scpSectionHeaderLength + scpSectionCount*10
+	lengths := make([]int, scpSectionCount)
+	offsets := make([]int, scpSectionCount)
+	lengths[0] = section0Length
+	offsets[0] = 6
+	next := 6 + section0Length
+	for id := 1; id < scpSectionCount; id++ {
+		if body, ok := bodies[id]; ok {
+			lengths[id] = scpSectionHeaderLength + len(body) + len(body)%2
+			offsets[id] = next
+			next += lengths[id]
+		}
+	}
+
+	section0 := &bytes.Buffer{}
+	for id := 0; id < scpSectionCount; id++ {
+		binary.Write(section0, binary.LittleEndian, uint16(id))
+		binary.Write(section0, binary.LittleEndian, uint32(lengths[id]))
+		index := uint32(0)
+		if lengths[id] > 0 {
+			index = uint32(offsets[id] + 1) // indexes are 1-based
+		}
+		binary.Write(section0, binary.LittleEndian, index)
+	}
+
+	record := make([]byte, 6, next)
+	record = append(record, scpSection(0, section0.Bytes())...)
+	for id := 1; id < scpSectionCount; id++ {
+		if body, ok := bodies[id]; ok {
+			record = append(record, scpSection(id, body)...)
+		}
+	}
+
+	binary.LittleEndian.PutUint32(record[2:], uint32(len(record)))
+	binary.LittleEndian.PutUint16(record, scpCRC(record[2:]))
+
+	_, err := w.Write(record)
+	return err
+}
+
+// scpSection prefixes body with its section ID header, padding it to an even length
+func scpSection(id int, body []byte) []byte {
+	length := scpSectionHeaderLength + len(body) + len(body)%2
+	section := make([]byte, length)
+	binary.LittleEndian.PutUint16(section[2:], uint16(id))
+	binary.LittleEndian.PutUint32(section[4:], uint32(length))
+	section[8] = scpProtocolVersion
+	section[9] = scpProtocolVersion
+	if id == 0 {
+		copy(section[10:], "SCPECG")
+	}
+	copy(section[scpSectionHeaderLength:], body)
+	binary.LittleEndian.PutUint16(section, scpCRC(section[2:]))
+	return section
+}
+
+// writeSCPTag writes a section 1 tag, length and value
+func writeSCPTag(buf *bytes.Buffer, tag byte, value []byte) {
+	buf.WriteByte(tag)
+	binary.Write(buf, binary.LittleEndian, uint16(len(value)))
+	buf.Write(value)
+}
+
+// scpString returns s as a null-terminated SCP-ECG string
+func scpString(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// scpDeviceID builds the acquiring device identification of section 1 tag 14
+func scpDeviceID(ecg *EcgData) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(make([]byte, 6)) // institution, department and device numbers
+	buf.WriteByte(0)           // device type: cart
+	buf.WriteByte(255)         // manufacturer code: other
+
+	var info InfoBlockJSON
+	if ecg.Info != nil {
+		info = ecg.Info.ToJSON()
+	}
+
+	model := make([]byte, 6)
+	copy(model[:5], info.RecorderHardware)
+	buf.Write(model)
+
+	buf.WriteByte(scpProtocolVersion)
+	buf.WriteByte(0x90) // compatibility level: category I
+	buf.WriteByte(0)    // language: 8-bit ASCII
+	buf.WriteByte(0xc0) // capabilities: acquire and store
+
+	switch ecg.MainsFrequency {
+	case 50:
+		buf.WriteByte(1)
+	case 60:
+		buf.WriteByte(2)
+	default:
+		buf.WriteByte(0)
+	}
+
+	buf.Write(make([]byte, 16)) // reserved
+	buf.WriteByte(1)            // empty analysing program revision
+	buf.WriteByte(0)
+	buf.Write(scpString(info.PhoneUDID))
+	buf.Write(scpString(info.RecorderSoftware))
+	buf.Write(scpString("atc2json"))
+	buf.Write(scpString("AliveCor"))
+	return buf.Bytes()
+}
+
+// scpCRC calculates the CRC-CCITT (polynomial 0x1021, initial value 0xffff) used by SCP-ECG
+func scpCRC(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
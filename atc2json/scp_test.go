@@ -0,0 +1,68 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSCPCRC(t *testing.T) {
+	assert.Equal(t, uint16(0x29b1), scpCRC([]byte("123456789")))
+}
+
+func TestWriteSCP(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	err = WriteSCP(buf, ecg)
+	assert.Nil(t, err)
+
+	out := buf.Bytes()
+	assert.Equal(t, uint32(len(out)), binary.LittleEndian.Uint32(out[2:]))
+	assert.Equal(t, scpCRC(out[2:]), binary.LittleEndian.Uint16(out))
+
+	// Every section in the pointer table has a valid ID header and CRC
+	sections := map[uint16][]byte{}
+	for i := 0; i < scpSectionCount; i++ {
+		entry := out[6+scpSectionHeaderLength+i*10:]
+		id := binary.LittleEndian.Uint16(entry)
+		length := int(binary.LittleEndian.Uint32(entry[2:]))
+		index := int(binary.LittleEndian.Uint32(entry[6:]))
+		assert.Equal(t, uint16(i), id)
+		if length == 0 {
+			assert.Equal(t, 0, index)
+			continue
+		}
+
+		section := out[index-1 : index-1+length]
+		assert.Equal(t, id, binary.LittleEndian.Uint16(section[2:]))
+		assert.Equal(t, uint32(length), binary.LittleEndian.Uint32(section[4:]))
+		assert.Equal(t, scpCRC(section[2:]), binary.LittleEndian.Uint16(section))
+		sections[id] = section[scpSectionHeaderLength:]
+	}
+	assert.Equal(t, 4, len(sections))
+	assert.Equal(t, "SCPECG", string(out[6+10:6+16]))
+
+	section3 := sections[3]
+	assert.Equal(t, byte(1), section3[0])
+	assert.Equal(t, uint32(9000), binary.LittleEndian.Uint32(section3[6:]))
+	assert.Equal(t, byte(1), section3[10])
+
+	section6 := sections[6]
+	assert.Equal(t, uint16(500), binary.LittleEndian.Uint16(section6))
+	assert.Equal(t, uint16(3333), binary.LittleEndian.Uint16(section6[2:]))
+	assert.Equal(t, uint16(18000), binary.LittleEndian.Uint16(section6[6:]))
+	samples := make([]int16, 9000)
+	binary.Read(bytes.NewReader(section6[8:]), binary.LittleEndian, samples)
+	assert.Equal(t, ecg.Samples.LeadI, samples)
+}
+
+func TestWriteSCPNoLeads(t *testing.T) {
+	err := WriteSCP(&bytes.Buffer{}, &EcgData{Frequency: 300, Gain: 2000})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,51 @@
+package atc2json
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// sliceDateLayout is the layout of the DateRecorded written by Slice, short
+// enough for the 32 byte field with millisecond precision
+const sliceDateLayout = "2006-01-02T15:04:05.999Z07:00"
+
+// Slice returns the part of the recording from startSec to endSec, in seconds from
+// the start, as a new recording with copies of the samples of every present lead.
+// Leads shorter than the window are cut at their end. When the info block holds a
+// parseable recording date it is moved forward to the start of the window. The
+// window must lie within Duration and the fields set by Convert are left empty.
+func (ecg *EcgData) Slice(startSec, endSec float64) (*EcgData, error) {
+	if ecg.Frequency <= 0 {
+		return nil, fmt.Errorf("Invalid frequency: %v", ecg.Frequency)
+	}
+	duration := ecg.Duration().Seconds()
+	if startSec < 0 || endSec <= startSec || endSec > duration {
+		return nil, fmt.Errorf("Invalid time window %v-%vs, recording is %vs long", startSec, endSec, duration)
+	}
+
+	start := int(math.Round(startSec * float64(ecg.Frequency)))
+	end := int(math.Round(endSec * float64(ecg.Frequency)))
+
+	result := copyMetadata(ecg)
+	leads := ecg.Samples.leadRefs()
+	for i, ref := range result.Samples.leadRefs() {
+		lead := *leads[i]
+		if lead == nil {
+			continue
+		}
+		leadStart, leadEnd := minInt(start, len(lead)), minInt(end, len(lead))
+		*ref = append([]int16{}, lead[leadStart:leadEnd]...)
+	}
+
+	if ecg.Info != nil {
+		if recordedAt, err := ecg.Info.RecordedAt(); err == nil {
+			info := *ecg.Info
+			info.DateRecorded = [32]byte{}
+			offset := time.Duration(math.Round(float64(start) * float64(time.Second) / float64(ecg.Frequency)))
+			copy(info.DateRecorded[:], recordedAt.Add(offset).Format(sliceDateLayout))
+			result.Info = &info
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestSlice(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	clip, err := ecg.Slice(10.5, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, ecg.Samples.LeadI[3150:6000], clip.Samples.LeadI)
+	assert.Nil(t, clip.Samples.LeadII)
+	assert.Equal(t, 9500*time.Millisecond, clip.Duration())
+	assert.Equal(t, ecg.Gain, clip.Gain)
+
+	start, ok := clip.SampleTime(0)
+	assert.True(t, ok)
+	expected, _ := ecg.SampleTime(3150)
+	assert.True(t, expected.Equal(start))
+	assert.Equal(t, "2012-04-03T14:17:53.5-07:00", clip.Info.ToJSON().DateRecorded)
+	assert.Equal(t, "2012-04-03T14:17:43-7:00", ecg.Info.ToJSON().DateRecorded)
+
+	// The clip does not share samples with the recording
+	clip.Samples.LeadI[0]++
+	assert.NotEqual(t, ecg.Samples.LeadI[3150], clip.Samples.LeadI[0])
+
+	_, err = ecg.Slice(0, 30)
+	assert.Nil(t, err)
+	for _, window := range [][2]float64{{-1, 5}, {5, 5}, {6, 5}, {0, 31}} {
+		_, err = ecg.Slice(window[0], window[1])
+		assert.NotNil(t, err, "window %v", window)
+	}
+	_, err = ecg.Slice(0, 31)
+	assert.EqualError(t, err, "Invalid time window 0-31s, recording is 30s long")
+}
@@ -0,0 +1,82 @@
+package atc2json
+
+import "math"
+
+// LeadStats summarizes the amplitude of a lead. Min and Max are sample counts,
+// MeanMv and RMSMv are in millivolts. ClippingFraction is the fraction of samples
+// at or beyond SaturationLevel, see ClippingReport.
+type LeadStats struct {
+	Min              int16   `json:"min"`
+	Max              int16   `json:"max"`
+	MeanMv           float32 `json:"meanMv"`
+	RMSMv            float32 `json:"rmsMv"`
+	ClippingFraction float64 `json:"clippingFraction"`
+}
+
+// Stats returns the LeadStats of every present lead with samples, keyed by lead name.
+// gain is the sample counts per millivolt; the millivolt fields are 0 unless it is positive.
+// A flatline lead has Min == Max and a clipped lead reaches the int16 limits.
+func (s *EcgSamples) Stats(gain float32) map[string]LeadStats {
+	return s.statsWithGains(func(string) float32 { return gain })
+}
+
+// statsWithGains is Stats scaling each lead by leadGain of its name, as for the
+// per-lead gains of EcgData.LeadGain
+func (s *EcgSamples) statsWithGains(leadGain func(name string) float32) map[string]LeadStats {
+	stats := map[string]LeadStats{}
+	for _, lead := range s.Leads() {
+		if len(lead.Samples) == 0 {
+			continue
+		}
+
+		leadStats := LeadStats{Min: lead.Samples[0], Max: lead.Samples[0]}
+		var sum, sumSquares float64
+		clipped := 0
+		for _, v := range lead.Samples {
+			if isClipped(v, SaturationLevel) {
+				clipped++
+			}
+			if v < leadStats.Min {
+				leadStats.Min = v
+			}
+			if v > leadStats.Max {
+				leadStats.Max = v
+			}
+			sum += float64(v)
+			sumSquares += float64(v) * float64(v)
+		}
+
+		n := float64(len(lead.Samples))
+		leadStats.ClippingFraction = float64(clipped) / n
+		if gain := leadGain(lead.Name); gain > 0 {
+			leadStats.MeanMv = float32(sum / n / float64(gain))
+			leadStats.RMSMv = float32(math.Sqrt(sumSquares/n) / float64(gain))
+		}
+		stats[lead.Name] = leadStats
+	}
+	return stats
+}
+
+// ClippingReport returns the fraction of samples of every present lead with samples
+// whose magnitude is at or above threshold, keyed by lead name. With SaturationLevel
+// as the threshold it finds recordings railed at the ADC limits.
+func (s *EcgSamples) ClippingReport(threshold int16) map[string]float64 {
+	report := map[string]float64{}
+	for _, lead := range s.Leads() {
+		if len(lead.Samples) == 0 {
+			continue
+		}
+		clipped := 0
+		for _, v := range lead.Samples {
+			if isClipped(v, threshold) {
+				clipped++
+			}
+		}
+		report[lead.Name] = float64(clipped) / float64(len(lead.Samples))
+	}
+	return report
+}
+
+func isClipped(v int16, threshold int16) bool {
+	return v >= threshold || int32(v) <= -int32(threshold)
+}
@@ -0,0 +1,50 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := &EcgSamples{
+		LeadI:  []int16{-1000, 1000, -1000, 1000},
+		LeadII: []int16{7, 7, 7},
+		V1:     []int16{math.MinInt16, 0, math.MaxInt16},
+		V2:     []int16{},
+	}
+
+	stats := s.Stats(1000)
+	assert.Equal(t, map[string]LeadStats{
+		"leadI":  {Min: -1000, Max: 1000, MeanMv: 0, RMSMv: 1},
+		"leadII": {Min: 7, Max: 7, MeanMv: 0.007, RMSMv: 0.007},
+		"v1":     {Min: math.MinInt16, Max: math.MaxInt16, MeanMv: -0.00033333333, RMSMv: 26.75455, ClippingFraction: 2.0 / 3},
+	}, stats)
+
+	stats = s.Stats(0)
+	assert.Equal(t, LeadStats{Min: -1000, Max: 1000}, stats["leadI"])
+}
+
+func TestClippingReport(t *testing.T) {
+	s := &EcgSamples{
+		LeadI:  []int16{-1000, 1000, -1000, 1000},
+		LeadII: []int16{math.MinInt16, -32000, 31999, 32000},
+		V2:     []int16{},
+	}
+
+	assert.Equal(t, map[string]float64{"leadI": 0, "leadII": 0.75}, s.ClippingReport(SaturationLevel))
+	assert.Equal(t, map[string]float64{"leadI": 1, "leadII": 1}, s.ClippingReport(1000))
+}
+
+func TestAnalyzeLeadGains(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		LeadGains: map[string]float32{"leadII": 1000},
+		Samples:   EcgSamples{LeadI: []int16{2000, 2000}, LeadII: []int16{2000, 2000}},
+	}
+	ecg.Analyze()
+	assert.Equal(t, float32(1), ecg.Stats["leadI"].MeanMv)
+	assert.Equal(t, float32(2), ecg.Stats["leadII"].MeanMv)
+	assert.Equal(t, float32(2), ecg.Stats["leadII"].RMSMv)
+}
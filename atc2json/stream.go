@@ -0,0 +1,82 @@
+package atc2json
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// StreamChunkSize is the number of samples per lead in each ConvertStream sample line
+const StreamChunkSize = 512
+
+// StreamHeader is the first line written by ConvertStream
+type StreamHeader struct {
+	Frequency           float32      `json:"frequency"`
+	AmplitudeResolution int          `json:"amplitudeResolution"`
+	MainsFrequency      int          `json:"mainsFrequency"`
+	Gain                float32      `json:"gain"`
+	Format              SampleFormat `json:"format"`
+	Leads               []string     `json:"leads"`
+	Info                *InfoBlock   `json:"Info"`
+}
+
+// StreamChunk is a batch of consecutive samples of one lead starting at sample index Start
+type StreamChunk struct {
+	Lead    string  `json:"lead"`
+	Start   int     `json:"start"`
+	Samples []int16 `json:"samples"`
+}
+
+// ConvertStream writes atcData to w as newline delimited JSON: a StreamHeader
+// line followed by StreamChunk lines. Chunks are written in time order with
+// the chunks of every lead for one time range before the next range.
+func ConvertStream(w io.Writer, atcData []byte) error {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return err
+	}
+
+	leads := ecgData.Samples.Leads()
+	header := StreamHeader{
+		Frequency:           ecgData.Frequency,
+		AmplitudeResolution: ecgData.AmplitudeResolution,
+		MainsFrequency:      ecgData.MainsFrequency,
+		Gain:                ecgData.Gain,
+		Format:              ecgData.Format,
+		Leads:               []string{},
+		Info:                ecgData.Info,
+	}
+
+	maxLen := 0
+	for _, lead := range leads {
+		header.Leads = append(header.Leads, lead.Name)
+		if len(lead.Samples) > maxLen {
+			maxLen = len(lead.Samples)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	err = encoder.Encode(&header)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < maxLen; start += StreamChunkSize {
+		for _, lead := range leads {
+			if start >= len(lead.Samples) {
+				continue
+			}
+
+			end := start + StreamChunkSize
+			if end > len(lead.Samples) {
+				end = len(lead.Samples)
+			}
+
+			err = encoder.Encode(&StreamChunk{Lead: lead.Name, Start: start, Samples: lead.Samples[start:end]})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package atc2json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConvertStream(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Samples: EcgSamples{
+			LeadI:  make([]int16, StreamChunkSize+10),
+			LeadII: make([]int16, 5),
+		},
+	}
+	ecg.Samples.LeadI[StreamChunkSize] = 42
+	atcData, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	err = ConvertStream(buf, atcData)
+	assert.Nil(t, err)
+
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(nil, 1<<20)
+
+	assert.True(t, scanner.Scan())
+	header := StreamHeader{}
+	assert.Nil(t, json.Unmarshal(scanner.Bytes(), &header))
+	assert.Equal(t, []string{"leadI", "leadII"}, header.Leads)
+	assert.Equal(t, float32(300), header.Frequency)
+
+	var chunks []StreamChunk
+	for scanner.Scan() {
+		chunk := StreamChunk{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &chunk))
+		chunks = append(chunks, chunk)
+	}
+
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, "leadI", chunks[0].Lead)
+	assert.Len(t, chunks[0].Samples, StreamChunkSize)
+	assert.Equal(t, "leadII", chunks[1].Lead)
+	assert.Len(t, chunks[1].Samples, 5)
+	assert.Equal(t, StreamChunk{Lead: "leadI", Start: StreamChunkSize, Samples: append([]int16{42}, make([]int16, 9)...)}, chunks[2])
+}
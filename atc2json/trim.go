@@ -0,0 +1,96 @@
+package atc2json
+
+const (
+	// SaturationLevel is the magnitude at or above which a sample counts as railed
+	SaturationLevel = 32000
+
+	// MinArtifactRun is the number of samples a leading or trailing run of
+	// saturated or flat samples must reach before it is trimmed
+	MinArtifactRun = 30
+)
+
+// TrimArtifacts drops leading and trailing runs of at least MinArtifactRun samples
+// that are saturated or flat, as recorded while electrodes are attached or removed.
+// trimmed shares memory with samples and startOffset is the index of its first sample.
+func TrimArtifacts(samples []int16) (trimmed []int16, startOffset int) {
+	start, end := artifactBounds(samples)
+	return samples[start:end], start
+}
+
+// TrimLeadArtifacts trims every lead to the same range, dropping the samples any
+// lead marks as artifact so that the leads stay aligned. It returns the number of
+// samples removed from the start.
+func (ecg *EcgData) TrimLeadArtifacts() int {
+	leads := ecg.Samples.leadRefs()
+
+	start, end := 0, -1
+	for _, ref := range leads {
+		if *ref == nil {
+			continue
+		}
+		leadStart, leadEnd := artifactBounds(*ref)
+		if leadStart > start {
+			start = leadStart
+		}
+		if end < 0 || leadEnd < end {
+			end = leadEnd
+		}
+	}
+	if end < start {
+		end = start
+	}
+
+	for _, ref := range leads {
+		if *ref == nil {
+			continue
+		}
+		leadStart, leadEnd := start, end
+		if leadEnd > len(*ref) {
+			leadEnd = len(*ref)
+		}
+		if leadStart > leadEnd {
+			leadStart = leadEnd
+		}
+		*ref = (*ref)[leadStart:leadEnd]
+	}
+	return start
+}
+
+// artifactBounds returns the range of samples left after trimming artifact runs
+func artifactBounds(samples []int16) (start, end int) {
+	start = artifactRun(len(samples), func(i int) int16 { return samples[i] })
+	if start == len(samples) {
+		return start, start
+	}
+	end = len(samples) - artifactRun(len(samples), func(i int) int16 { return samples[len(samples)-1-i] })
+	return start, end
+}
+
+// artifactRun returns the length of the artifact at the start of the n samples
+// returned by at, or 0 when it is shorter than MinArtifactRun. The artifact is made
+// of saturated samples and of flat stretches, repeats of a single value at least
+// MinArtifactRun long, so that plateaus in the signal itself are kept.
+func artifactRun(n int, at func(i int) int16) int {
+	run := 0
+	for run < n {
+		v := at(run)
+		if v >= SaturationLevel || v <= -SaturationLevel {
+			run++
+			continue
+		}
+
+		flat := run + 1
+		for flat < n && at(flat) == v {
+			flat++
+		}
+		if flat-run < MinArtifactRun {
+			break
+		}
+		run = flat
+	}
+
+	if run < MinArtifactRun {
+		return 0
+	}
+	return run
+}
@@ -0,0 +1,58 @@
+package atc2json
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestTrimArtifacts(t *testing.T) {
+	signal := syntheticEcg(2, 300, 75)
+
+	var samples []int16
+	for i := 0; i < 40; i++ {
+		samples = append(samples, math.MaxInt16)
+	}
+	for i := 0; i < 30; i++ {
+		samples = append(samples, 500)
+	}
+	samples = append(samples, signal...)
+	for i := 0; i < 50; i++ {
+		samples = append(samples, math.MinInt16)
+	}
+
+	trimmed, start := TrimArtifacts(samples)
+	assert.Equal(t, 70, start)
+	assert.Equal(t, signal, trimmed)
+
+	// Short runs are kept
+	short := append([]int16{5, 5, 5, math.MaxInt16, 500, 500}, signal...)
+	trimmed, start = TrimArtifacts(short)
+	assert.Equal(t, 0, start)
+	assert.Equal(t, short, trimmed)
+
+	trimmed, start = TrimArtifacts(make([]int16, 100))
+	assert.Equal(t, 100, start)
+	assert.Equal(t, 0, len(trimmed))
+}
+
+func TestTrimLeadArtifacts(t *testing.T) {
+	signal := syntheticEcg(1, 300, 75)
+	flat := func(n int) []int16 {
+		samples := make([]int16, n)
+		for i := range samples {
+			samples[i] = 500
+		}
+		return samples
+	}
+	leadI := append(flat(50), signal...)
+	leadII := append(flat(40), signal[:260]...)
+	leadII = append(leadII, flat(100)...)
+
+	ecg := &EcgData{Samples: EcgSamples{LeadI: leadI, LeadII: leadII}}
+	start := ecg.TrimLeadArtifacts()
+	assert.Equal(t, 50, start)
+	assert.Equal(t, signal[:250], ecg.Samples.LeadI)
+	assert.Equal(t, signal[10:260], ecg.Samples.LeadII)
+	assert.Nil(t, ecg.Samples.LeadIII)
+}
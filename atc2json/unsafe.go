@@ -0,0 +1,35 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// nativeLittleEndian reports whether int16 values are stored little-endian in
+// memory on this host, in which case ATC sample bytes can be used as is
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// Int16Samples returns the little-endian int16 samples stored in data, ignoring
+// a trailing odd byte. On little-endian hosts, when data is 2-byte aligned, the
+// result shares memory with data instead of copying it, so data must not be
+// modified while the samples are in use. Otherwise the samples are decoded
+// into a new slice.
+func Int16Samples(data []byte) []int16 {
+	n := len(data) / 2
+	if n == 0 {
+		return []int16{}
+	}
+
+	if nativeLittleEndian && uintptr(unsafe.Pointer(&data[0]))%unsafe.Alignof(int16(0)) == 0 {
+		return unsafe.Slice((*int16)(unsafe.Pointer(&data[0])), n)
+	}
+
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return samples
+}
@@ -0,0 +1,76 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestInt16Samples(t *testing.T) {
+	data := []byte{0x01, 0x00, 0xff, 0xff, 0x00, 0x80, 0x07}
+	assert.Equal(t, []int16{1, -1, -32768}, Int16Samples(data))
+
+	// Unaligned input is decoded into a copy
+	assert.Equal(t, []int16{-1, -32768}, Int16Samples(data[2:6]))
+	assert.Equal(t, []int16{-256, 255}, Int16Samples(data[1:6]))
+	assert.Equal(t, []int16{}, Int16Samples(data[:1]))
+}
+
+func TestDecodeSamples(t *testing.T) {
+	expected := []int16{0, 1, -1, 32767, -32768}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, order, expected)
+		buf.WriteByte(9)
+		assert.Equal(t, expected, decodeSamples(buf.Bytes(), order))
+	}
+}
+
+func TestReadBytesLargeLength(t *testing.T) {
+	// A corrupt length does not allocate more than maxBlockPrealloc ahead of the data
+	data, err := readBytes(bytes.NewReader([]byte{1, 0, 2, 0}), 0xfffffffe)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, []byte{1, 0, 2, 0}, data)
+
+	data, err = readBytes(bytes.NewReader(make([]byte, 3*maxBlockPrealloc)), 3*maxBlockPrealloc)
+	assert.Nil(t, err)
+	assert.Len(t, data, 3*maxBlockPrealloc)
+}
+
+func benchmarkSampleData() []byte {
+	buf := &bytes.Buffer{}
+	samples := make([]int16, 30*300)
+	for i := range samples {
+		samples[i] = int16(i)
+	}
+	binary.Write(buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+func BenchmarkReadSamplesBinaryRead(b *testing.B) {
+	data := benchmarkSampleData()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		samples := make([]int16, len(data)/2)
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, samples)
+	}
+}
+
+func BenchmarkReadSamplesNative(b *testing.B) {
+	data := benchmarkSampleData()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		body, _ := readBytes(bytes.NewReader(data), uint32(len(data)))
+		Int16Samples(body)
+	}
+}
+
+func BenchmarkInt16Samples(b *testing.B) {
+	data := benchmarkSampleData()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Int16Samples(data)
+	}
+}
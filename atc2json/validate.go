@@ -0,0 +1,187 @@
+package atc2json
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// BlockLocation describes where a block sits in an ATC file. Length is the
+// declared body length, excluding the block header and checksum.
+type BlockLocation struct {
+	ID         string `json:"id"`
+	Offset     int64  `json:"offset"`
+	Length     uint32 `json:"length"`
+	ChecksumOK bool   `json:"checksumOK"`
+}
+
+// Validate checks the file signature and walks every block of atcData, verifying
+// that each block fits in the buffer and that its checksum matches. Samples are
+// not decoded. The first problem found is returned.
+func Validate(atcData []byte) error {
+	return ValidateWithOptions(atcData, ParseOptions{})
+}
+
+// ValidateWithOptions is Validate reading lengths and checksums in opts.ByteOrder
+func ValidateWithOptions(atcData []byte, opts ParseOptions) error {
+	return walkBlocks(atcData, opts.byteOrder(), func(loc BlockLocation, checksumErr *ChecksumError) error {
+		if checksumErr != nil {
+			return checksumErr
+		}
+		return nil
+	})
+}
+
+// Index returns the location of every block in atcData without decoding samples.
+// A trailing whole-file checksum is reported as a zero length FileChecksumBlockID
+// block. When a block header cannot be read the blocks found so far are returned
+// with an error giving the offset.
+func Index(atcData []byte) ([]BlockLocation, error) {
+	return IndexWithOptions(atcData, ParseOptions{})
+}
+
+// IndexWithOptions is Index reading lengths and checksums in opts.ByteOrder
+func IndexWithOptions(atcData []byte, opts ParseOptions) ([]BlockLocation, error) {
+	var locations []BlockLocation
+	err := walkBlocks(atcData, opts.byteOrder(), func(loc BlockLocation, checksumErr *ChecksumError) error {
+		locations = append(locations, loc)
+		return nil
+	})
+	return locations, err
+}
+
+// Blocks calls fn with the id and body of every block in atcData in file order,
+// after checking the signature, that the block fits and that its checksum matches.
+// A trailing whole-file checksum is verified but not passed to fn. body shares
+// memory with atcData. Iteration stops at the first error, including one
+// returned by fn.
+func Blocks(atcData []byte, fn func(id string, body []byte) error) error {
+	return BlocksWithOptions(atcData, ParseOptions{}, fn)
+}
+
+// BlocksWithOptions is Blocks reading lengths and checksums in opts.ByteOrder
+func BlocksWithOptions(atcData []byte, opts ParseOptions, fn func(id string, body []byte) error) error {
+	blockHeaderLen := int64(binary.Size(BlockHeader{}))
+
+	return walkBlocks(atcData, opts.byteOrder(), func(loc BlockLocation, checksumErr *ChecksumError) error {
+		if checksumErr != nil {
+			return checksumErr
+		}
+		if loc.ID == FileChecksumBlockID {
+			return nil
+		}
+		start := loc.Offset + blockHeaderLen
+		return fn(loc.ID, atcData[start:start+int64(loc.Length)])
+	})
+}
+
+// LeadsPresent returns the names of the leads in atcData, e.g. "leadI", in file
+// order. Only block headers are read; samples are not decoded and checksums are
+// not verified.
+func LeadsPresent(atcData []byte) ([]string, error) {
+	return LeadsPresentWithOptions(atcData, ParseOptions{})
+}
+
+// LeadsPresentWithOptions is LeadsPresent reading block lengths in opts.ByteOrder
+func LeadsPresentWithOptions(atcData []byte, opts ParseOptions) ([]string, error) {
+	names := map[string]string{}
+	for _, def := range leadDefinitions {
+		names[def.BlockID] = def.Name
+	}
+
+	leads := []string{}
+	err := scanBlocks(atcData, opts.byteOrder(), func(blockId string, offset int64, length uint32) error {
+		if name, ok := names[blockId]; ok {
+			leads = append(leads, name)
+			delete(names, blockId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return leads, nil
+}
+
+// walkBlocks calls fn for every block in atcData with the result of verifying its
+// checksum, stored in the given byte order, stopping at the first error
+func walkBlocks(atcData []byte, order binary.ByteOrder, fn func(loc BlockLocation, checksumErr *ChecksumError) error) error {
+	return scanBlocks(atcData, order, func(blockId string, offset int64, length uint32) error {
+		if int64(len(atcData))-offset == ChecksumLength {
+			err := verifyFileChecksum(atcData[offset:], order, offset, calcChecksum(atcData[:offset]))
+			checksumErr, _ := err.(*ChecksumError)
+			return fn(BlockLocation{ID: FileChecksumBlockID, Offset: offset, ChecksumOK: err == nil}, checksumErr)
+		}
+
+		checksumErr := verifyBlockChecksum(atcData, order, blockId, offset, length)
+		return fn(BlockLocation{ID: blockId, Offset: offset, Length: length, ChecksumOK: checksumErr == nil}, checksumErr)
+	})
+}
+
+// verifyBlockChecksum checks the checksum stored after the block of atcData at
+// offset, which must fit in atcData
+func verifyBlockChecksum(atcData []byte, order binary.ByteOrder, blockId string, offset int64, length uint32) *ChecksumError {
+	bodyEnd := offset + int64(binary.Size(BlockHeader{})) + int64(length)
+	expected := order.Uint32(atcData[bodyEnd : bodyEnd+ChecksumLength])
+	calculated := calcChecksum(atcData[offset:bodyEnd])
+	if expected != calculated {
+		return &ChecksumError{
+			BlockID:    blockId,
+			Offset:     offset,
+			Expected:   expected,
+			Calculated: calculated,
+		}
+	}
+	return nil
+}
+
+// scanBlocks calls fn with the id, offset and body length, read in the given byte
+// order, of every block in atcData after checking that the block fits, stopping at
+// the first error. A trailing whole-file checksum is passed as FileChecksumBlockID.
+func scanBlocks(atcData []byte, order binary.ByteOrder, fn func(blockId string, offset int64, length uint32) error) error {
+	return scanBlocksUntil(atcData, order, nil, fn)
+}
+
+// scanBlocksUntil is scanBlocks ending the walk without an error at the first block
+// whose id stop returns true for. That block is not checked to fit, so a truncated
+// block after the ones of interest is not an error. stop may be nil.
+func scanBlocksUntil(atcData []byte, order binary.ByteOrder, stop func(blockId string) bool, fn func(blockId string, offset int64, length uint32) error) error {
+	offset := int64(binary.Size(AtcFileHeader{}))
+	dataLen := int64(len(atcData))
+
+	if dataLen < offset || !hasAtcSignature(atcData) {
+		return fmt.Errorf("Wrong file signature")
+	}
+
+	blockHeaderLen := int64(binary.Size(BlockHeader{}))
+
+	for offset < dataLen {
+		if dataLen-offset == ChecksumLength {
+			return fn(FileChecksumBlockID, offset, 0)
+		}
+
+		// Only the id of the block stopped at needs to be in atcData
+		if stop != nil && dataLen-offset >= 4 && stop(string(atcData[offset:offset+4])) {
+			return nil
+		}
+		if dataLen-offset < blockHeaderLen {
+			return fmt.Errorf("Truncated block header at offset %d", offset)
+		}
+
+		blockId := string(atcData[offset : offset+4])
+		length := order.Uint32(atcData[offset+4 : offset+8])
+		bodyEnd := offset + blockHeaderLen + int64(length)
+
+		if bodyEnd+ChecksumLength > dataLen {
+			return fmt.Errorf("Block %q at offset %d with length %d extends past end of file", blockId, offset, length)
+		}
+
+		err := fn(blockId, offset, length)
+		if err != nil {
+			return err
+		}
+
+		offset = bodyEnd + ChecksumLength
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package atc2json
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	assert.Nil(t, Validate(atcData))
+
+	assert.NotNil(t, Validate(atcData[:4]))
+	assert.NotNil(t, Validate(atcData[:len(atcData)-1]))
+	assert.NotNil(t, Validate(atcData[:len(atcData)-ChecksumLength-2]))
+	assert.NotNil(t, Validate(append(append([]byte{}, atcData...), 'x')))
+
+	corrupt := append([]byte{}, atcData...)
+	corrupt[100]++
+	err = Validate(corrupt)
+	assert.IsType(t, &ChecksumError{}, err)
+	assert.Equal(t, "info", err.(*ChecksumError).BlockID)
+}
+
+func TestIndex(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	locations, err := Index(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, []BlockLocation{
+		{ID: "info", Offset: 12, Length: 264, ChecksumOK: true},
+		{ID: "fmt ", Offset: 288, Length: 8, ChecksumOK: true},
+		{ID: "ecg ", Offset: 308, Length: 18000, ChecksumOK: true},
+	}, locations)
+
+	corrupt := append([]byte{}, atcData[:len(atcData)-2]...)
+	corrupt[300]++
+	locations, err = Index(corrupt)
+	assert.EqualError(t, err, `Block "ecg " at offset 308 with length 18000 extends past end of file`)
+	assert.Equal(t, []BlockLocation{
+		{ID: "info", Offset: 12, Length: 264, ChecksumOK: true},
+		{ID: "fmt ", Offset: 288, Length: 8, ChecksumOK: false},
+	}, locations)
+}
+
+func TestBlocks(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	var ids []string
+	var lengths []int
+	err = Blocks(atcData, func(id string, body []byte) error {
+		ids = append(ids, id)
+		lengths = append(lengths, len(body))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"info", "fmt ", "ecg "}, ids)
+	assert.Equal(t, []int{264, 8, 18000}, lengths)
+
+	// A callback error stops iteration
+	stop := errors.New("stop")
+	ids = nil
+	err = Blocks(atcData, func(id string, body []byte) error {
+		ids = append(ids, id)
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []string{"info"}, ids)
+
+	corrupt := append([]byte{}, atcData...)
+	corrupt[300]++
+	ids = nil
+	err = Blocks(corrupt, func(id string, body []byte) error {
+		ids = append(ids, id)
+		return nil
+	})
+	assert.IsType(t, &ChecksumError{}, err)
+	assert.Equal(t, []string{"info"}, ids)
+}
+
+func TestLeadsPresent(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+
+	leads, err := LeadsPresent(atcData)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"leadI"}, leads)
+
+	ecg := &EcgData{
+		Frequency:           300,
+		AmplitudeResolution: 500,
+		Format:              SampleFormatRaw,
+		Samples:             EcgSamples{LeadI: []int16{1}, LeadII: []int16{2}, V1: []int16{3}},
+	}
+	encoded, err := Encode(ecg)
+	assert.Nil(t, err)
+
+	// Checksums are not verified
+	encoded[len(encoded)-5]++
+	leads, err = LeadsPresent(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"leadI", "leadII", "v1"}, leads)
+
+	_, err = LeadsPresent(atcData[:400])
+	assert.NotNil(t, err)
+}
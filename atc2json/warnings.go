@@ -0,0 +1,32 @@
+package atc2json
+
+import "fmt"
+
+// Codes of the soft issues reported in EcgData.Warnings
+const (
+	WarningLeadLength      = "leadLength"
+	WarningGain            = "gain"
+	WarningUnknownBlock    = "unknownBlock"
+	WarningEmptyLead       = "emptyLead"
+	WarningDuplicateLead   = "duplicateLead"
+	WarningChecksumSkipped = "checksumSkipped"
+)
+
+// Gains outside MinPlausibleGain to MaxPlausibleGain LSB/mV, 0.1 to 10 µV per
+// sample count, are reported as a WarningGain
+const (
+	MinPlausibleGain = 100
+	MaxPlausibleGain = 10000
+)
+
+// Warning is an anomaly found while parsing that does not prevent the recording
+// from being read, such as leads of different lengths
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// warn records a warning with the formatted message
+func (ecg *EcgData) warn(code string, format string, args ...interface{}) {
+	ecg.Warnings = append(ecg.Warnings, Warning{Code: code, Message: fmt.Sprintf(format, args...)})
+}
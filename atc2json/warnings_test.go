@@ -0,0 +1,42 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseWarnings(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &AtcFileHeader{FileSignature: AtcFileSignature, FileVersion: 2})
+	assert.Nil(t, writeBlock(buf, "fmt ", &FmtBlock{Format: byte(SampleFormatRaw), Frequency: 300, Resolution: 50}))
+	assert.Nil(t, writeBlock(buf, "ecg ", []int16{1, 2}))
+	assert.Nil(t, writeBlock(buf, "ecg3", []int16{}))
+	assert.Nil(t, writeBlock(buf, "ann ", []byte{1, 2, 3}))
+	assert.Nil(t, writeBlock(buf, "ecg ", []int16{3, 4, 5}))
+	assert.Nil(t, writeBlock(buf, "ecg2", []int16{7}))
+
+	ecg, err := Parse(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, []Warning{
+		{WarningEmptyLead, `Empty "ecg3" block at offset 48, lead treated as absent`},
+		{WarningUnknownBlock, `Skipped unknown "ann " block at offset 60`},
+		{WarningDuplicateLead, `Duplicate "ecg " block at offset 75 replaces the earlier one`},
+		{WarningLeadLength, "leadII has 2 fewer samples than leadI (1 vs 3)"},
+		{WarningGain, "Gain 20000 LSB/mV is outside the plausible range 100 to 10000"},
+	}, ecg.Warnings)
+
+	output, err := json.Marshal(ecg)
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `"warnings":[{"code":"emptyLead",`)
+
+	// A clean recording has none
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err = Parse(atcData)
+	assert.Nil(t, err)
+	assert.Nil(t, ecg.Warnings)
+}
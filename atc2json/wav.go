@@ -0,0 +1,70 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const wavHeaderLength = 44
+
+// WAVOptions controls the WAV file written by WriteWAVLeadWithOptions
+type WAVOptions struct {
+	// Speedup multiplies the WAV sample rate relative to the ECG frequency, raising the
+	// pitch and shortening playback. Defaults to 1.
+	Speedup float32
+}
+
+// WriteWAV writes samples to w as a 16-bit mono PCM WAV file at sampleRate Hz
+func WriteWAV(w io.Writer, samples []int16, sampleRate float32) error {
+	rate := math.Round(float64(sampleRate))
+	if rate < 1 || rate > math.MaxUint32/2 {
+		return fmt.Errorf("Invalid WAV sample rate %v", sampleRate)
+	}
+	dataLength := len(samples) * 2
+	if dataLength > math.MaxUint32-wavHeaderLength {
+		return fmt.Errorf("Too many samples for WAV: %d", len(samples))
+	}
+
+	header := &bytes.Buffer{}
+	header.WriteString("RIFF")
+	binary.Write(header, binary.LittleEndian, uint32(wavHeaderLength-8+dataLength))
+	header.WriteString("WAVEfmt ")
+	binary.Write(header, binary.LittleEndian, uint32(16))     // fmt chunk length
+	binary.Write(header, binary.LittleEndian, uint16(1))      // PCM
+	binary.Write(header, binary.LittleEndian, uint16(1))      // mono
+	binary.Write(header, binary.LittleEndian, uint32(rate))   // sample rate
+	binary.Write(header, binary.LittleEndian, uint32(rate*2)) // byte rate
+	binary.Write(header, binary.LittleEndian, uint16(2))      // block align
+	binary.Write(header, binary.LittleEndian, uint16(16))     // bits per sample
+	header.WriteString("data")
+	binary.Write(header, binary.LittleEndian, uint32(dataLength))
+
+	_, err := w.Write(header.Bytes())
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, samples)
+}
+
+// WriteWAVLead writes the named lead, e.g. "leadII" or "II", as a WAV file at the
+// recording frequency
+func (ecg *EcgData) WriteWAVLead(w io.Writer, lead string) error {
+	return ecg.WriteWAVLeadWithOptions(w, lead, WAVOptions{})
+}
+
+// WriteWAVLeadWithOptions writes the named lead as a WAV file, sped up by opts.Speedup
+func (ecg *EcgData) WriteWAVLeadWithOptions(w io.Writer, lead string, opts WAVOptions) error {
+	samples := ecg.Samples.Lead(lead)
+	if samples == nil {
+		return fmt.Errorf("Lead %q not present", lead)
+	}
+
+	speedup := opts.Speedup
+	if speedup <= 0 {
+		speedup = 1
+	}
+	return WriteWAV(w, samples, ecg.Frequency*speedup)
+}
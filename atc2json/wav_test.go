@@ -0,0 +1,40 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWriteWAV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteWAV(buf, []int16{1, -1, 256}, 300)
+	assert.Nil(t, err)
+
+	out := buf.Bytes()
+	assert.Equal(t, 44+6, len(out))
+	assert.Equal(t, "RIFF", string(out[:4]))
+	assert.Equal(t, uint32(42), binary.LittleEndian.Uint32(out[4:]))
+	assert.Equal(t, "WAVEfmt ", string(out[8:16]))
+	assert.Equal(t, uint32(300), binary.LittleEndian.Uint32(out[24:]))
+	assert.Equal(t, uint32(600), binary.LittleEndian.Uint32(out[28:]))
+	assert.Equal(t, "data", string(out[36:40]))
+	assert.Equal(t, uint32(6), binary.LittleEndian.Uint32(out[40:]))
+	assert.Equal(t, []byte{1, 0, 0xff, 0xff, 0, 1}, out[44:])
+
+	err = WriteWAV(buf, nil, 0)
+	assert.NotNil(t, err)
+}
+
+func TestWriteWAVLead(t *testing.T) {
+	ecg := &EcgData{Frequency: 300, Samples: EcgSamples{LeadII: []int16{1, 2}}}
+
+	buf := &bytes.Buffer{}
+	err := ecg.WriteWAVLeadWithOptions(buf, "II", WAVOptions{Speedup: 20})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(6000), binary.LittleEndian.Uint32(buf.Bytes()[24:]))
+
+	err = ecg.WriteWAVLead(&bytes.Buffer{}, "leadI")
+	assert.NotNil(t, err)
+}
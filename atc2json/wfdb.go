@@ -0,0 +1,109 @@
+package atc2json
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// wfdbInvalidSample marks samples past the end of a shorter lead in format 16
+const wfdbInvalidSample = math.MinInt16
+
+// WriteWFDB writes ecg as a WFDB record: base.hea describing every present lead and
+// base.dat holding the interleaved samples in format 16. Leads shorter than the
+// longest one are padded with the WFDB invalid sample value.
+func WriteWFDB(base string, ecg *EcgData) error {
+	hea, err := os.Create(base + ".hea")
+	if err != nil {
+		return err
+	}
+	defer hea.Close()
+
+	dat, err := os.Create(base + ".dat")
+	if err != nil {
+		return err
+	}
+	defer dat.Close()
+
+	err = writeWFDB(filepath.Base(base), ecg, hea, dat)
+	if err != nil {
+		return err
+	}
+
+	err = hea.Close()
+	if err != nil {
+		return err
+	}
+	return dat.Close()
+}
+
+func writeWFDB(record string, ecg *EcgData, hea io.Writer, dat io.Writer) error {
+	leads := ecg.Samples.Leads()
+	if len(leads) == 0 {
+		return fmt.Errorf("No leads to write")
+	}
+	if strings.ContainsAny(record, " \t") {
+		return fmt.Errorf("Invalid WFDB record name %q", record)
+	}
+
+	length := 0
+	for _, lead := range leads {
+		if len(lead.Samples) > length {
+			length = len(lead.Samples)
+		}
+	}
+
+	frame := make([]int16, len(leads))
+	checksums := make([]int16, len(leads))
+	datWriter := bufio.NewWriter(dat)
+
+	for i := 0; i < length; i++ {
+		for j, lead := range leads {
+			frame[j] = wfdbInvalidSample
+			if i < len(lead.Samples) {
+				frame[j] = lead.Samples[i]
+			}
+			checksums[j] += frame[j]
+		}
+
+		err := binary.Write(datWriter, binary.LittleEndian, frame)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := datWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	gain := strconv.FormatFloat(float64(ecg.Gain), 'f', -1, 32)
+	frequency := strconv.FormatFloat(float64(ecg.Frequency), 'f', -1, 32)
+
+	_, err = fmt.Fprintf(hea, "%s %d %s %d\n", record, len(leads), frequency, length)
+	if err != nil {
+		return err
+	}
+
+	for j, lead := range leads {
+		var initial int16
+		if len(lead.Samples) > 0 {
+			initial = lead.Samples[0]
+		}
+
+		// file format gain(baseline)/units resolution zero initial checksum blocksize description
+		_, err = fmt.Fprintf(hea, "%s.dat 16 %s(0)/mV 16 0 %d %d 0 %s\n",
+			record, gain, initial, checksums[j], shortLeadName(lead.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
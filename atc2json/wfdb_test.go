@@ -0,0 +1,42 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteWFDB(t *testing.T) {
+	ecg := &EcgData{
+		Frequency: 300,
+		Gain:      2000,
+		Samples: EcgSamples{
+			LeadI:  []int16{10, 20, 30},
+			LeadII: []int16{-5, 32767},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "wfdb")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "rec1")
+	err = WriteWFDB(base, ecg)
+	assert.Nil(t, err)
+
+	hea, err := ioutil.ReadFile(base + ".hea")
+	assert.Nil(t, err)
+	assert.Equal(t, "rec1 2 300 3\n"+
+		"rec1.dat 16 2000(0)/mV 16 0 10 60 0 I\n"+
+		"rec1.dat 16 2000(0)/mV 16 0 -5 -6 0 II\n", string(hea))
+
+	dat, err := ioutil.ReadFile(base + ".dat")
+	assert.Nil(t, err)
+	samples := make([]int16, 6)
+	binary.Read(bytes.NewReader(dat), binary.LittleEndian, samples)
+	assert.Equal(t, []int16{10, -5, 20, 32767, 30, -32768}, samples)
+}
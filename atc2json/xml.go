@@ -0,0 +1,149 @@
+package atc2json
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xmlRootElement is the name of the element ConvertXML wraps the recording in
+const xmlRootElement = "ecg"
+
+// ConvertXML is Convert with XML output. Elements are named after the JSON fields
+// and follow the omitempty rules, null values such as absent leads are left out.
+// Numeric arrays such as the samples are space-separated text, other arrays repeat
+// an item element and byte slices are base64 text as in JSON.
+func ConvertXML(atcData []byte) (string, error) {
+	ecgData, err := Parse(atcData)
+	if err != nil {
+		return "", err
+	}
+
+	ecgData.summarize()
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	err = encodeXML(enc, xmlRootElement, reflect.ValueOf(ecgData))
+	if err != nil {
+		return "", err
+	}
+	err = enc.Flush()
+	return buf.String(), err
+}
+
+// encodeXML writes v as the element name, following the encoding/json mapping of Go values
+func encodeXML(enc *xml.Encoder, name string, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr && !v.Type().Implements(jsonMarshalerType) {
+			return encodeXML(enc, name, v.Elem())
+		}
+	}
+
+	// Types with custom JSON encodings are written from their decoded JSON
+	if v.Type().Implements(jsonMarshalerType) {
+		decoded, err := decodeMarshaler(v)
+		if err != nil {
+			return err
+		}
+		return encodeXML(enc, name, decoded)
+	}
+	if v.Kind() == reflect.Interface {
+		return encodeXML(enc, name, v.Elem())
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		names, values := jsonFields(v)
+		for i := range names {
+			err = encodeXML(enc, names[i], values[i])
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			err = encodeXML(enc, key, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		elem := v.Type().Elem().Kind()
+		switch {
+		case elem == reflect.Uint8:
+			err = enc.EncodeToken(xml.CharData(base64.StdEncoding.EncodeToString(v.Bytes())))
+		case elem >= reflect.Int && elem <= reflect.Float64:
+			values := make([]string, v.Len())
+			for i := range values {
+				values[i], err = xmlText(v.Index(i))
+				if err != nil {
+					return err
+				}
+			}
+			err = enc.EncodeToken(xml.CharData(strings.Join(values, " ")))
+		default:
+			for i := 0; i < v.Len(); i++ {
+				err = encodeXML(enc, "item", v.Index(i))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		var text string
+		text, err = xmlText(v)
+		if err == nil {
+			err = enc.EncodeToken(xml.CharData(text))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlText formats a scalar like encoding/json, without quoting strings
+func xmlText(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			return n.String(), nil
+		}
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("Unsupported XML type: %s", v.Type())
+	}
+}
@@ -0,0 +1,42 @@
+package atc2json
+
+import (
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestConvertXML(t *testing.T) {
+	atcData, err := ioutil.ReadFile("../fixtures/normal-v2.atc")
+	assert.Nil(t, err)
+	ecg, err := Parse(atcData)
+	assert.Nil(t, err)
+
+	output, err := ConvertXML(atcData)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(output, xml.Header+"<ecg><frequency>300</frequency>"))
+	assert.Contains(t, output, "<mainsFrequency>60</mainsFrequency>")
+	assert.Contains(t, output, "<recordingUUID>1285733B-9A84-4349-A845-52FCC436353F</recordingUUID>")
+	assert.Contains(t, output, "<leadInfo><leadI><sampleCount>9000</sampleCount><duration>30</duration></leadI></leadInfo>")
+	assert.NotContains(t, output, "<beats>")
+	assert.NotContains(t, output, "<leadII>")
+
+	var decoded struct {
+		Samples struct {
+			LeadI string `xml:"leadI"`
+		} `xml:"samples"`
+	}
+	assert.Nil(t, xml.Unmarshal([]byte(output), &decoded))
+	fields := strings.Fields(decoded.Samples.LeadI)
+	assert.Len(t, fields, len(ecg.Samples.LeadI))
+	for i, field := range fields {
+		v, err := strconv.Atoi(field)
+		assert.Nil(t, err)
+		if int16(v) != ecg.Samples.LeadI[i] {
+			t.Fatalf("Sample %d is %s, expected %d", i, field, ecg.Samples.LeadI[i])
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/alivecor/atc2json/atc2json"
+)
+
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	in, out := ioFlags(fs)
+	pretty := fs.Bool("pretty", false, "emit indented JSON")
+	validate := fs.Bool("validate", false, "only validate the input, printing nothing unless it is invalid")
+	lead := fs.String("lead", "", "only output the samples of this lead, e.g. leadII")
+	units := fs.String("units", "raw", "sample units: raw counts, uV for integer microvolts or mV for millivolts")
+	analyze := fs.Bool("analyze", false, "add the heart rate, beats, stats, lead-off intervals and content hash")
+	keepUnknown := fs.Bool("keep-unknown", false, "keep unrecognized blocks, so reverse reproduces them")
+	from, to := clipFlags(fs)
+	fs.Parse(args)
+
+	if *validate {
+		run(*in, "", "", func(w io.Writer, atcData []byte) error {
+			return atc2json.Validate(atcData)
+		})
+		return
+	}
+
+	unitMode, ok := map[string]atc2json.UnitMode{"raw": atc2json.UnitRaw, "uV": atc2json.UnitMicrovolts, "mV": atc2json.UnitMillivolts}[*units]
+	if !ok {
+		log.Fatalf("Unknown units %q, expected raw, uV or mV", *units)
+	}
+	if *analyze && unitMode != atc2json.UnitRaw {
+		log.Fatalf("-analyze only works with -units raw")
+	}
+	opts := atc2json.ParseOptions{UnitMode: unitMode, Analyze: *analyze, KeepUnknownBlocks: *keepUnknown}
+
+	if *lead != "" {
+		run(*in, *out, ".json", clipped(opts, *from, *to, func(w io.Writer, ecgData *atc2json.EcgData) error {
+			output, err := convertLead(ecgData, *lead, *pretty)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, output)
+			return err
+		}))
+		return
+	}
+	if *pretty {
+		opts.Indent = "  "
+	}
+	run(*in, *out, ".json", clipped(opts, *from, *to, func(w io.Writer, ecgData *atc2json.EcgData) error {
+		return atc2json.EncodeWithOptions(w, ecgData, opts)
+	}))
+}
+
+// clipFlags registers the -from and -to flags selecting a time window of the recording
+func clipFlags(fs *flag.FlagSet) (from *float64, to *float64) {
+	from = fs.Float64("from", 0, "start of the time window to convert, in seconds")
+	to = fs.Float64("to", 0, "end of the time window to convert, in seconds (default end of recording)")
+	return from, to
+}
+
+// clipped adapts fn, which writes a parsed recording, to the ATC data taken by run.
+// The data is parsed with opts and only the from-to seconds window of the recording
+// is passed to fn, see EcgData.Slice. A to of 0 means the end of the recording.
+// Without a window the whole recording is passed.
+func clipped(opts atc2json.ParseOptions, from, to float64, fn func(w io.Writer, ecgData *atc2json.EcgData) error) func(w io.Writer, atcData []byte) error {
+	return func(w io.Writer, atcData []byte) error {
+		ecgData, _, err := atc2json.ParseWithOptions(atcData, opts)
+		if err != nil {
+			return err
+		}
+		if from == 0 && to == 0 {
+			return fn(w, ecgData)
+		}
+		end := to
+		if end == 0 {
+			end = ecgData.Duration().Seconds()
+		}
+		clip, err := ecgData.Slice(from, end)
+		if err != nil {
+			return err
+		}
+		return fn(w, clip)
+	}
+}
+
+// singleLead is the output of convert -lead, one lead with the metadata needed to scale it
+type singleLead struct {
+	Frequency           float32             `json:"frequency"`
+	AmplitudeResolution int                 `json:"amplitudeResolution"`
+	MainsFrequency      int                 `json:"mainsFrequency"`
+	Gain                float32             `json:"gain"`
+	Lead                string              `json:"lead"`
+	Samples             []int16             `json:"samples"`
+	Info                *atc2json.InfoBlock `json:"Info"`
+}
+
+// convertLead returns the JSON of the named lead of ecgData, or an error listing
+// the leads present when it is missing
+func convertLead(ecgData *atc2json.EcgData, name string, pretty bool) (string, error) {
+	samples, ok := ecgData.Lead(name)
+	if !ok {
+		var available []string
+		for _, lead := range ecgData.Samples.Leads() {
+			available = append(available, lead.Name)
+		}
+		return "", fmt.Errorf("Unknown lead %q, available leads: %s", name, strings.Join(available, ", "))
+	}
+
+	output := &singleLead{
+		Frequency:           ecgData.Frequency,
+		AmplitudeResolution: ecgData.AmplitudeResolution,
+		MainsFrequency:      ecgData.MainsFrequency,
+		Gain:                ecgData.Gain,
+		Lead:                name,
+		Samples:             samples,
+		Info:                ecgData.Info,
+	}
+
+	var data []byte
+	var err error
+	if pretty {
+		data, err = json.MarshalIndent(output, "", "  ")
+	} else {
+		data, err = json.Marshal(output)
+	}
+	return string(data), err
+}
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	in, out := ioFlags(fs)
+	fs.Parse(args)
+
+	run(*in, *out, ".txt", stringOutput(func(atcData []byte) (string, error) {
+		err := atc2json.Validate(atcData)
+		if err != nil {
+			return "", err
+		}
+		return "ok\n", nil
+	}))
+}
+
+// metadata is the output of the info command, EcgData without the samples
+type metadata struct {
+	Frequency            float32                      `json:"frequency"`
+	AmplitudeResolution  int                          `json:"amplitudeResolution"`
+	MainsFrequency       int                          `json:"mainsFrequency"`
+	Gain                 float32                      `json:"gain"`
+	Format               atc2json.SampleFormat        `json:"format"`
+	Flags                int                          `json:"flags"`
+	FmtFlags             atc2json.FmtFlags            `json:"fmtFlags"`
+	FmtReserved          int                          `json:"fmtReserved"`
+	FileVersion          int                          `json:"fileVersion"`
+	SignatureSubtype     string                       `json:"signatureSubtype,omitempty"`
+	Info                 *atc2json.InfoBlock          `json:"Info"`
+	LeadInfo             map[string]atc2json.LeadInfo `json:"leadInfo,omitempty"`
+	FileChecksumVerified bool                         `json:"fileChecksumVerified,omitempty"`
+}
+
+func runInfo(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	in, out := ioFlags(fs)
+	fs.Parse(args)
+
+	run(*in, *out, ".json", stringOutput(func(atcData []byte) (string, error) {
+		ecgData, err := atc2json.Parse(atcData)
+		if err != nil {
+			return "", err
+		}
+
+		output, err := json.MarshalIndent(&metadata{
+			Frequency:            ecgData.Frequency,
+			AmplitudeResolution:  ecgData.AmplitudeResolution,
+			MainsFrequency:       ecgData.MainsFrequency,
+			Gain:                 ecgData.Gain,
+			Format:               ecgData.Format,
+			Flags:                ecgData.Flags,
+			FmtFlags:             ecgData.FmtFlags,
+			FmtReserved:          ecgData.FmtReserved,
+			FileVersion:          ecgData.FileVersion,
+			SignatureSubtype:     ecgData.SignatureSubtype,
+			Info:                 ecgData.Info,
+			LeadInfo:             ecgData.CalcLeadInfo(),
+			FileChecksumVerified: ecgData.FileChecksumVerified,
+		}, "", "  ")
+		return string(output), err
+	}))
+}
+
+func runCSV(args []string) {
+	fs := flag.NewFlagSet("csv", flag.ExitOnError)
+	in, out := ioFlags(fs)
+	includeTime := fs.Bool("time", false, "add a leading time column in seconds")
+	includeTimestamp := fs.Bool("timestamp", false, "add a column with the absolute time of each sample")
+	from, to := clipFlags(fs)
+	fs.Parse(args)
+
+	opts := atc2json.CSVOptions{IncludeTime: *includeTime, IncludeTimestamp: *includeTimestamp}
+	run(*in, *out, ".csv", clipped(atc2json.ParseOptions{}, *from, *to, func(w io.Writer, ecgData *atc2json.EcgData) error {
+		return atc2json.WriteCSVWithOptions(w, ecgData, opts)
+	}))
+}
+
+func runReverse(args []string) {
+	fs := flag.NewFlagSet("reverse", flag.ExitOnError)
+	in, out := ioFlags(fs)
+	fs.Parse(args)
+
+	runPattern(*in, *out, "*.json", ".atc", func(w io.Writer, jsonData []byte) error {
+		atcData, err := atc2json.EncodeJSON(jsonData)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(atcData)
+		return err
+	})
+}
+
+func runPreview(args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	in, out := ioFlags(fs)
+	points := fs.Int("points", atc2json.DefaultPreviewPoints, "number of samples per lead")
+	fs.Parse(args)
+
+	run(*in, *out, ".json", stringOutput(func(atcData []byte) (string, error) {
+		preview, err := atc2json.Preview(atcData, *points)
+		if err != nil {
+			return "", err
+		}
+		output, err := json.Marshal(preview)
+		return string(output), err
+	}))
+}
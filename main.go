@@ -1,28 +1,194 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
-
-	"github.com/alivecor/atc2json/atc2json"
+	"path/filepath"
+	"strings"
 )
 
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
+
+var commands = []command{
+	{"convert", "convert ATC to JSON (default)", runConvert},
+	{"validate", "check signature, block layout and checksums", runValidate},
+	{"info", "print recording metadata without samples", runInfo},
+	{"csv", "convert ATC to CSV", runCSV},
+	{"reverse", "convert JSON written by convert back to ATC", runReverse},
+	{"preview", "write a min/max decimated preview of every lead as JSON", runPreview},
+}
+
 func main() {
-	atcData, err := ioutil.ReadAll(os.Stdin)
+	// Without a subcommand the arguments are convert flags, as before subcommands existed
+	name := "convert"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(args)
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [-in path] [-out path] [flags]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// ioFlags registers the -in and -out flags shared by every command
+func ioFlags(fs *flag.FlagSet) (in *string, out *string) {
+	in = fs.String("in", "", "input .atc file, or a directory of .atc files (default stdin)")
+	out = fs.String("out", "", "output file, or a directory when -in is a directory (default stdout)")
+	return in, out
+}
+
+// run reads ATC data from inPath, converts it with fn and writes the result to outPath.
+// When inPath is a directory every *.atc file in it is converted to <name><ext> in
+// the outPath directory, or printed to stdout on its own line without -out.
+func run(inPath string, outPath string, ext string, fn func(w io.Writer, atcData []byte) error) {
+	runPattern(inPath, outPath, "*.atc", ext, fn)
+}
+
+// runPattern is run converting the files matching pattern when inPath is a directory
+func runPattern(inPath string, outPath string, pattern string, ext string, fn func(w io.Writer, atcData []byte) error) {
+	if inPath == "" {
+		atcData, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		writeOutput(outPath, atcData, fn)
+		return
+	}
+
+	info, err := os.Stat(inPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !info.IsDir() {
+		atcData, err := ioutil.ReadFile(inPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		writeOutput(outPath, atcData, fn)
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(inPath, pattern))
 	if err != nil {
 		log.Fatal(err)
+	}
+
+	if outPath != "" {
+		err = os.MkdirAll(outPath, 0755)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, path := range paths {
+		atcData, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Without -out each file is written to stdout as its own line
+		if outPath == "" {
+			w := &lineWriter{w: os.Stdout}
+			err = fn(w, atcData)
+			if err == nil {
+				err = w.endLine()
+			}
+			if err != nil {
+				log.Fatalf("%s: %s", path, err)
+			}
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ext
+		writeOutput(filepath.Join(outPath, name), atcData, fn)
+	}
+}
+
+// stringOutput adapts a conversion returning a string to run
+func stringOutput(fn func(atcData []byte) (string, error)) func(w io.Writer, atcData []byte) error {
+	return func(w io.Writer, atcData []byte) error {
+		output, err := fn(atcData)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, output)
+		return err
+	}
+}
+
+// writeOutput converts atcData with fn straight to the file at path, or stdout when
+// path is empty. A file left incomplete by an error is removed.
+func writeOutput(path string, atcData []byte, fn func(w io.Writer, atcData []byte) error) {
+	if path == "" {
+		err := fn(os.Stdout, atcData)
+		if err != nil {
+			log.Fatalln(err)
+		}
 		return
 	}
 
-	jsonOut, err := atc2json.Convert(atcData)
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	err = fn(f, atcData)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
 	if err != nil {
+		os.Remove(path)
 		log.Fatalln(err)
 	}
+}
+
+// lineWriter tracks whether the output written so far ends with a newline
+type lineWriter struct {
+	w       io.Writer
+	last    byte
+	written bool
+}
 
-	fmt.Printf(jsonOut)
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		lw.last = p[len(p)-1]
+		lw.written = true
+	}
+	return lw.w.Write(p)
+}
 
-	return
+// endLine terminates the output with a newline unless it is empty or already ends with one
+func (lw *lineWriter) endLine() error {
+	if !lw.written || lw.last == '\n' {
+		return nil
+	}
+	_, err := io.WriteString(lw.w, "\n")
+	return err
 }